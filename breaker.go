@@ -0,0 +1,201 @@
+package fazpass
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// BreakerState is the state of a BreakerFlow.
+type BreakerState int
+
+const (
+	// StateClosed lets every request through.
+	StateClosed BreakerState = iota
+	// StateOpen fails every request with ErrCircuitOpen until the cool-down ends.
+	StateOpen
+	// StateHalfOpen lets a few probe requests through to decide whether to close.
+	StateHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// BreakerSettings configures a BreakerFlow. Zero values fall back to defaults.
+type BreakerSettings struct {
+	// FailureThreshold is the number of consecutive failures opening the circuit, 5 by default.
+	FailureThreshold int
+	// CoolDown is how long the circuit stays open before probing, 30 seconds by default.
+	CoolDown time.Duration
+	// HalfOpenRequests is the number of concurrent probes allowed while half-open, 1 by default.
+	HalfOpenRequests int
+	// IsFailure tells whether a request failed, by default errors, timeouts
+	// included, and 5xx statuses. A request canceled by its caller is neither
+	// a failure nor a success and is not passed to IsFailure.
+	IsFailure func(response *http.Response, err error) bool
+	// OnStateChange is called after every state change.
+	OnStateChange func(from BreakerState, to BreakerState)
+}
+
+// BreakerFlow wraps the SendingData step of a flow with a circuit breaker,
+// so an unavailable Fazpass fails fast instead of piling up requests.
+type BreakerFlow struct {
+	FlowInterface
+	settings BreakerSettings
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probes   int
+	now      func() time.Time
+}
+
+// NewBreakerFlow wraps flow with a circuit breaker.
+func NewBreakerFlow(flow FlowInterface, settings BreakerSettings) *BreakerFlow {
+	if settings.FailureThreshold <= 0 {
+		settings.FailureThreshold = 5
+	}
+	if settings.CoolDown <= 0 {
+		settings.CoolDown = 30 * time.Second
+	}
+	if settings.HalfOpenRequests <= 0 {
+		settings.HalfOpenRequests = 1
+	}
+	if settings.IsFailure == nil {
+		settings.IsFailure = isFailure
+	}
+	return &BreakerFlow{FlowInterface: flow, settings: settings, now: time.Now}
+}
+
+// isFailure counts a deadline exceeded as a failure, as callers giving up on
+// their own deadlines is what a slow Fazpass looks like.
+func isFailure(response *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return response.StatusCode >= http.StatusInternalServerError
+}
+
+// outcome is how a request let through by acquire ended.
+type outcome int
+
+const (
+	succeeded outcome = iota
+	failed
+	// neutral requests, canceled by their caller, say nothing of Fazpass.
+	neutral
+)
+
+// State returns the current state of the breaker.
+func (b *BreakerFlow) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == StateOpen && b.now().Sub(b.openedAt) >= b.settings.CoolDown {
+		return StateHalfOpen
+	}
+	return b.state
+}
+
+func (b *BreakerFlow) WrappingDataContext(ctx context.Context, pubKey *rsa.PublicKey, model interface{}) ([]byte, error) {
+	return withContext(b.FlowInterface).WrappingDataContext(ctx, pubKey, model)
+}
+
+func (b *BreakerFlow) SendingData(baseUrl string, wrappedMessage []byte, merchantKey string) (*http.Response, error) {
+	return b.SendingDataContext(context.Background(), baseUrl, wrappedMessage, merchantKey)
+}
+
+func (b *BreakerFlow) SendingDataContext(ctx context.Context, baseUrl string, wrappedMessage []byte, merchantKey string) (*http.Response, error) {
+	if err := b.acquire(); err != nil {
+		return nil, err
+	}
+	response, err := withContext(b.FlowInterface).SendingDataContext(ctx, baseUrl, wrappedMessage, merchantKey)
+	switch {
+	case errors.Is(err, context.Canceled):
+		b.release(neutral)
+	case b.settings.IsFailure(response, err):
+		b.release(failed)
+	default:
+		b.release(succeeded)
+	}
+	return response, err
+}
+
+func (b *BreakerFlow) ExtractingDataContext(ctx context.Context, decrypter crypto.Decrypter, response *http.Response, data *Data) (*Data, error) {
+	return withContext(b.FlowInterface).ExtractingDataContext(ctx, decrypter, response, data)
+}
+
+// acquire lets a request through, or returns ErrCircuitOpen.
+func (b *BreakerFlow) acquire() error {
+	b.mu.Lock()
+	from := b.state
+	if b.state == StateOpen && b.now().Sub(b.openedAt) >= b.settings.CoolDown {
+		b.state = StateHalfOpen
+		b.probes = 0
+	}
+	allowed := b.state == StateClosed || (b.state == StateHalfOpen && b.probes < b.settings.HalfOpenRequests)
+	if allowed && b.state == StateHalfOpen {
+		b.probes++
+	}
+	to := b.state
+	b.mu.Unlock()
+	b.notify(from, to)
+	if !allowed {
+		return ErrCircuitOpen
+	}
+	return nil
+}
+
+// release records the outcome of a request let through by acquire. A neutral
+// outcome only frees its half-open probe slot.
+func (b *BreakerFlow) release(result outcome) {
+	b.mu.Lock()
+	from := b.state
+	switch {
+	case result == neutral:
+		if b.state == StateHalfOpen && b.probes > 0 {
+			b.probes--
+		}
+	case b.state == StateHalfOpen && result == failed:
+		b.open()
+	case b.state == StateHalfOpen:
+		b.state = StateClosed
+		b.failures = 0
+	case b.state == StateClosed && result == failed:
+		b.failures++
+		if b.failures >= b.settings.FailureThreshold {
+			b.open()
+		}
+	case b.state == StateClosed:
+		b.failures = 0
+	}
+	to := b.state
+	b.mu.Unlock()
+	b.notify(from, to)
+}
+
+func (b *BreakerFlow) open() {
+	b.state = StateOpen
+	b.openedAt = b.now()
+	b.failures = 0
+}
+
+func (b *BreakerFlow) notify(from BreakerState, to BreakerState) {
+	if from != to && b.settings.OnStateChange != nil {
+		b.settings.OnStateChange(from, to)
+	}
+}
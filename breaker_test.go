@@ -0,0 +1,129 @@
+package fazpass
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestBreakerFlow(t *testing.T) {
+	setup := func() (*FlowMock, *BreakerFlow, *[]string, *time.Time) {
+		f := new(FlowMock)
+		changes := &[]string{}
+		now := time.Now()
+		b := NewBreakerFlow(f, BreakerSettings{
+			FailureThreshold: 2,
+			CoolDown:         time.Minute,
+			OnStateChange: func(from BreakerState, to BreakerState) {
+				*changes = append(*changes, from.String()+">"+to.String())
+			},
+		})
+		b.now = func() time.Time { return now }
+		return f, b, changes, &now
+	}
+
+	t.Run("Open after consecutive failures", func(t *testing.T) {
+		f, b, changes, _ := setup()
+		f.On("SendingData", mock.Anything, mock.Anything, mock.Anything).Return((*http.Response)(nil), ErrTransport)
+		b.SendingData("http://localhost/check", nil, "MERCHANT_KEY")
+		assert.Equal(t, b.State(), StateClosed)
+		b.SendingData("http://localhost/check", nil, "MERCHANT_KEY")
+		assert.Equal(t, b.State(), StateOpen)
+
+		_, err := b.SendingData("http://localhost/check", nil, "MERCHANT_KEY")
+		assert.True(t, errors.Is(err, ErrCircuitOpen))
+		f.AssertNumberOfCalls(t, "SendingData", 2)
+		assert.Equal(t, *changes, []string{"closed>open"})
+	})
+	t.Run("Success resets failures", func(t *testing.T) {
+		f, b, _, _ := setup()
+		resp, _ := httpmock.NewJsonResponse(500, &ErrorResponse{})
+		f.On("SendingData", mock.Anything, mock.Anything, mock.Anything).Return(resp, nil).Once()
+		ok, _ := httpmock.NewJsonResponse(200, &Transmission{})
+		f.On("SendingData", mock.Anything, mock.Anything, mock.Anything).Return(ok, nil).Once()
+		f.On("SendingData", mock.Anything, mock.Anything, mock.Anything).Return(resp, nil).Once()
+		for i := 0; i < 3; i++ {
+			b.SendingData("http://localhost/check", nil, "MERCHANT_KEY")
+		}
+		assert.Equal(t, b.State(), StateClosed)
+	})
+	t.Run("Half-open probe closes the circuit", func(t *testing.T) {
+		f, b, changes, now := setup()
+		f.On("SendingData", mock.Anything, mock.Anything, mock.Anything).Return((*http.Response)(nil), ErrTransport).Twice()
+		b.SendingData("http://localhost/check", nil, "MERCHANT_KEY")
+		b.SendingData("http://localhost/check", nil, "MERCHANT_KEY")
+
+		*now = now.Add(time.Minute)
+		assert.Equal(t, b.State(), StateHalfOpen)
+		ok, _ := httpmock.NewJsonResponse(200, &Transmission{})
+		f.On("SendingData", mock.Anything, mock.Anything, mock.Anything).Return(ok, nil).Once()
+		_, err := b.SendingData("http://localhost/check", nil, "MERCHANT_KEY")
+		assert.Equal(t, err, nil)
+		assert.Equal(t, b.State(), StateClosed)
+		assert.Equal(t, *changes, []string{"closed>open", "open>half-open", "half-open>closed"})
+	})
+	t.Run("Half-open probe failure reopens the circuit", func(t *testing.T) {
+		f, b, _, now := setup()
+		f.On("SendingData", mock.Anything, mock.Anything, mock.Anything).Return((*http.Response)(nil), ErrTransport)
+		b.SendingData("http://localhost/check", nil, "MERCHANT_KEY")
+		b.SendingData("http://localhost/check", nil, "MERCHANT_KEY")
+
+		*now = now.Add(time.Minute)
+		b.SendingData("http://localhost/check", nil, "MERCHANT_KEY")
+		assert.Equal(t, b.State(), StateOpen)
+	})
+	t.Run("Cancellation is not a failure", func(t *testing.T) {
+		f, b, _, _ := setup()
+		f.On("SendingData", mock.Anything, mock.Anything, mock.Anything).Return((*http.Response)(nil), context.Canceled)
+		for i := 0; i < 3; i++ {
+			b.SendingData("http://localhost/check", nil, "MERCHANT_KEY")
+		}
+		assert.Equal(t, b.State(), StateClosed)
+	})
+	t.Run("Cancellation does not reset failures", func(t *testing.T) {
+		f, b, _, _ := setup()
+		f.On("SendingData", mock.Anything, mock.Anything, mock.Anything).Return((*http.Response)(nil), ErrTransport).Once()
+		f.On("SendingData", mock.Anything, mock.Anything, mock.Anything).Return((*http.Response)(nil), context.Canceled).Once()
+		f.On("SendingData", mock.Anything, mock.Anything, mock.Anything).Return((*http.Response)(nil), ErrTransport).Once()
+		for i := 0; i < 3; i++ {
+			b.SendingData("http://localhost/check", nil, "MERCHANT_KEY")
+		}
+		assert.Equal(t, b.State(), StateOpen)
+	})
+	t.Run("Timeouts alternating with transport errors", func(t *testing.T) {
+		f := new(FlowMock)
+		b := NewBreakerFlow(f, BreakerSettings{FailureThreshold: 3, CoolDown: time.Minute})
+		f.On("SendingData", mock.Anything, mock.Anything, mock.Anything).Return((*http.Response)(nil), ErrTransport).Once()
+		f.On("SendingData", mock.Anything, mock.Anything, mock.Anything).Return((*http.Response)(nil), context.DeadlineExceeded).Once()
+		f.On("SendingData", mock.Anything, mock.Anything, mock.Anything).Return((*http.Response)(nil), ErrTransport).Once()
+		for i := 0; i < 3; i++ {
+			b.SendingData("http://localhost/check", nil, "MERCHANT_KEY")
+		}
+		assert.Equal(t, b.State(), StateOpen)
+	})
+	t.Run("Canceled half-open probe leaves the circuit open", func(t *testing.T) {
+		f, b, changes, now := setup()
+		f.On("SendingData", mock.Anything, mock.Anything, mock.Anything).Return((*http.Response)(nil), ErrTransport).Twice()
+		b.SendingData("http://localhost/check", nil, "MERCHANT_KEY")
+		b.SendingData("http://localhost/check", nil, "MERCHANT_KEY")
+
+		*now = now.Add(time.Minute)
+		f.On("SendingData", mock.Anything, mock.Anything, mock.Anything).Return((*http.Response)(nil), context.Canceled).Once()
+		b.SendingData("http://localhost/check", nil, "MERCHANT_KEY")
+		assert.Equal(t, b.State(), StateHalfOpen)
+		assert.Equal(t, *changes, []string{"closed>open", "open>half-open"})
+
+		// The probe slot was freed, so the next request probes again.
+		ok, _ := httpmock.NewJsonResponse(200, &Transmission{})
+		f.On("SendingData", mock.Anything, mock.Anything, mock.Anything).Return(ok, nil).Once()
+		_, err := b.SendingData("http://localhost/check", nil, "MERCHANT_KEY")
+		assert.Equal(t, err, nil)
+		assert.Equal(t, b.State(), StateClosed)
+	})
+}
@@ -0,0 +1,137 @@
+package fazpass
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultBulkWorkers is how many requests a bulk call sends concurrently by
+// default.
+const DefaultBulkWorkers = 8
+
+// BulkOptions configures ValidateDevices and RemoveDevices.
+type BulkOptions struct {
+	// Workers bounds the concurrent requests, DefaultBulkWorkers when zero.
+	Workers int
+	// Rate bounds the requests started per second, unbounded when zero.
+	Rate float64
+	// StopOnError stops starting requests after the first failure, the
+	// remaining items fail with ErrSkipped.
+	StopOnError bool
+}
+
+// BulkResult is the outcome of one item of a bulk call.
+type BulkResult struct {
+	FazpassId string
+	Data      *Data
+	Err       error
+}
+
+// ValidateDevices validates devices concurrently, see BulkOptions. Results
+// are in the order of requests. Items not sent because ctx is done fail with
+// its error.
+func (f *Fazpass) ValidateDevices(ctx context.Context, requests []ValidateRequest, opts BulkOptions) []BulkResult {
+	fazpassIds := make([]string, len(requests))
+	for i, request := range requests {
+		fazpassIds[i] = request.FazpassId
+	}
+	return bulk(ctx, fazpassIds, opts, func(ctx context.Context, i int) (*Data, error) {
+		return f.ValidateDeviceContext(ctx, requests[i].FazpassId, requests[i].Data)
+	})
+}
+
+// RemoveDevices removes devices concurrently, e.g. every device of a
+// deactivated account, see ValidateDevices.
+func (f *Fazpass) RemoveDevices(ctx context.Context, requests []RemoveRequest, opts BulkOptions) []BulkResult {
+	fazpassIds := make([]string, len(requests))
+	for i, request := range requests {
+		fazpassIds[i] = request.FazpassId
+	}
+	return bulk(ctx, fazpassIds, opts, func(ctx context.Context, i int) (*Data, error) {
+		return f.RemoveDeviceContext(ctx, requests[i].FazpassId, requests[i].Data)
+	})
+}
+
+// bulk runs call for each item with bounded concurrency and rate.
+func bulk(ctx context.Context, fazpassIds []string, opts BulkOptions, call func(ctx context.Context, i int) (*Data, error)) []BulkResult {
+	results := make([]BulkResult, len(fazpassIds))
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = DefaultBulkWorkers
+	}
+	if workers > len(fazpassIds) {
+		workers = len(fazpassIds)
+	}
+	limiter := newRateLimiter(opts.Rate)
+	var stopped atomic.Bool
+	items := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range items {
+				results[i] = BulkResult{FazpassId: fazpassIds[i], Data: &Data{}}
+				if stopped.Load() {
+					results[i].Err = ErrSkipped
+					continue
+				}
+				if err := limiter.wait(ctx); err != nil {
+					results[i].Err = err
+					continue
+				}
+				// Another item may have failed while waiting.
+				if stopped.Load() {
+					results[i].Err = ErrSkipped
+					continue
+				}
+				results[i].Data, results[i].Err = call(ctx, i)
+				if results[i].Err != nil && opts.StopOnError {
+					stopped.Store(true)
+				}
+			}
+		}()
+	}
+	for i := range fazpassIds {
+		items <- i
+	}
+	close(items)
+	wg.Wait()
+	return results
+}
+
+// rateLimiter spaces the start of requests evenly.
+type rateLimiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+func newRateLimiter(rate float64) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / rate)}
+}
+
+// wait blocks until the next request may start or ctx is done.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	start := l.next
+	if start.Before(now) {
+		start = now
+	}
+	l.next = start.Add(l.interval)
+	l.mu.Unlock()
+	return wait(ctx, start.Sub(now))
+}
@@ -0,0 +1,119 @@
+package fazpass
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// bulkFlow answers with the fazpass id of the request, failing for the ids
+// of fail, and records how many requests were in flight.
+type bulkFlow struct {
+	fail  map[string]bool
+	delay time.Duration
+
+	mu        sync.Mutex
+	active    int
+	maxActive int
+	starts    []time.Time
+}
+
+func (flow *bulkFlow) WrappingData(pubKey *rsa.PublicKey, model interface{}) ([]byte, error) {
+	return json.Marshal(model)
+}
+
+func (flow *bulkFlow) SendingData(baseUrl string, wrappedMessage []byte, merchantKey string) (*http.Response, error) {
+	request := &RemoveRequest{}
+	json.Unmarshal(wrappedMessage, request)
+	flow.mu.Lock()
+	flow.active++
+	if flow.active > flow.maxActive {
+		flow.maxActive = flow.active
+	}
+	flow.starts = append(flow.starts, time.Now())
+	flow.mu.Unlock()
+	time.Sleep(flow.delay)
+	flow.mu.Lock()
+	flow.active--
+	flow.mu.Unlock()
+	if flow.fail[request.FazpassId] {
+		return nil, fmt.Errorf("%w: connection reset", ErrTransport)
+	}
+	return &http.Response{Header: http.Header{"X-Fazpass-Id": {request.FazpassId}}}, nil
+}
+
+func (flow *bulkFlow) ExtractingData(privKey *rsa.PrivateKey, response *http.Response, data *Data) (*Data, error) {
+	data.Device.FazpassId = response.Header.Get("X-Fazpass-Id")
+	return data, nil
+}
+
+func removeRequests(n int) []RemoveRequest {
+	requests := make([]RemoveRequest, n)
+	for i := range requests {
+		requests[i] = RemoveRequest{FazpassId: fmt.Sprintf("ID_%d", i), Data: "KOALA_PANDA"}
+	}
+	return requests
+}
+
+func TestBulk(t *testing.T) {
+	t.Run("Bounded concurrency", func(t *testing.T) {
+		flow := &bulkFlow{fail: map[string]bool{"ID_3": true}, delay: 5 * time.Millisecond}
+		results := testClient(t, flow).RemoveDevices(context.Background(), removeRequests(20), BulkOptions{Workers: 4})
+		assert.Equal(t, len(results), 20)
+		for i, result := range results {
+			assert.Equal(t, result.FazpassId, fmt.Sprintf("ID_%d", i))
+			if i == 3 {
+				assert.True(t, errors.Is(result.Err, ErrTransport))
+				continue
+			}
+			assert.Equal(t, result.Err, nil)
+			assert.Equal(t, result.Data.Device.FazpassId, result.FazpassId)
+		}
+		assert.Equal(t, flow.maxActive <= 4, true)
+		assert.Equal(t, len(flow.starts), 20)
+	})
+	t.Run("Validate", func(t *testing.T) {
+		flow := &bulkFlow{}
+		results := testClient(t, flow).ValidateDevices(context.Background(), []ValidateRequest{
+			{FazpassId: "ID_1", Data: "KOALA_PANDA"},
+			{FazpassId: "", Data: "KOALA_PANDA"},
+		}, BulkOptions{})
+		assert.Equal(t, results[0].Data.Device.FazpassId, "ID_1")
+		assert.Equal(t, results[1].Err, ErrValidation)
+	})
+	t.Run("Stop on error", func(t *testing.T) {
+		flow := &bulkFlow{fail: map[string]bool{"ID_0": true}}
+		results := testClient(t, flow).RemoveDevices(context.Background(), removeRequests(10), BulkOptions{Workers: 1, StopOnError: true})
+		assert.True(t, errors.Is(results[0].Err, ErrTransport))
+		for _, result := range results[1:] {
+			assert.Equal(t, result.Err, ErrSkipped)
+		}
+		assert.Equal(t, len(flow.starts), 1)
+	})
+	t.Run("Rate limit", func(t *testing.T) {
+		flow := &bulkFlow{}
+		testClient(t, flow).RemoveDevices(context.Background(), removeRequests(5), BulkOptions{Workers: 5, Rate: 100})
+		assert.True(t, flow.starts[4].Sub(flow.starts[0]) >= 35*time.Millisecond)
+	})
+	t.Run("Context canceled", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 25*time.Millisecond)
+		defer cancel()
+		flow := &bulkFlow{}
+		results := testClient(t, flow).RemoveDevices(ctx, removeRequests(10), BulkOptions{Rate: 100})
+		assert.Equal(t, results[0].Err, nil)
+		assert.Equal(t, results[9].Err, context.DeadlineExceeded)
+		assert.True(t, len(flow.starts) < 10)
+	})
+	t.Run("Empty", func(t *testing.T) {
+		results := testClient(t, &bulkFlow{}).RemoveDevices(context.Background(), nil, BulkOptions{})
+		assert.Equal(t, len(results), 0)
+	})
+}
@@ -0,0 +1,274 @@
+package fazpass
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Defaults of CacheConfig.
+const (
+	DefaultCacheTTL        = 30 * time.Second
+	DefaultCacheMaxEntries = 10000
+)
+
+// CacheEntry is a cached ValidateDevice result, either its data or the API
+// error rejecting the device.
+type CacheEntry struct {
+	Data  *Data     `json:"data,omitempty"`
+	Error *APIError `json:"error,omitempty"`
+}
+
+// Cache stores ValidateDevice results by fazpass id and key, the key being a
+// hash of the device data. Implementations must be safe for concurrent use,
+// a shared store can back them so several instances share results.
+type Cache interface {
+	Get(ctx context.Context, fazpassId string, key string) (*CacheEntry, bool)
+	Set(ctx context.Context, fazpassId string, key string, entry *CacheEntry, ttl time.Duration)
+	// Invalidate removes every entry of fazpassId.
+	Invalidate(ctx context.Context, fazpassId string)
+}
+
+// CacheConfig configures WithValidateCache, zero values select the defaults.
+type CacheConfig struct {
+	// Cache stores the results, an LRUCache of MaxEntries by default.
+	Cache Cache
+	// TTL of a successful result, DefaultCacheTTL when zero.
+	TTL time.Duration
+	// NegativeTTL of a device rejected by Fazpass as unknown, see
+	// DeviceRejected, TTL when zero. A negative value disables negative
+	// caching.
+	NegativeTTL time.Duration
+	// MaxEntries of the default cache, DefaultCacheMaxEntries when zero.
+	MaxEntries int
+}
+
+// WithValidateCache caches the results of ValidateDevice, so repeated calls
+// for the same device skip the round trip to Fazpass until they expire.
+// RemoveDevice and EnrollDevice invalidate the entries of the removed or
+// enrolled device.
+func WithValidateCache(config CacheConfig) Option {
+	return func(f *Fazpass) error {
+		if config.TTL < 0 || config.MaxEntries < 0 {
+			return errors.New("cache ttl and max entries cannot be negative")
+		}
+		if config.TTL == 0 {
+			config.TTL = DefaultCacheTTL
+		}
+		if config.NegativeTTL == 0 {
+			config.NegativeTTL = config.TTL
+		}
+		if config.MaxEntries == 0 {
+			config.MaxEntries = DefaultCacheMaxEntries
+		}
+		if config.Cache == nil {
+			config.Cache = NewLRUCache(config.MaxEntries)
+		}
+		f.cache = &config
+		f.removals = &removals{devices: map[string]*removal{}}
+		return nil
+	}
+}
+
+// cacheKey hashes encData, so the device data is not kept in the cache.
+func cacheKey(encData string) string {
+	sum := sha256.Sum256([]byte(encData))
+	return hex.EncodeToString(sum[:])
+}
+
+// validateCached runs validate unless its result is cached.
+func (f *Fazpass) validateCached(ctx context.Context, fazpassId string, encData string, validate func() (*Data, error)) (*Data, error) {
+	if f.cache == nil || fazpassId == "" || encData == "" {
+		return validate()
+	}
+	key := cacheKey(encData)
+	if entry, ok := f.cache.Cache.Get(ctx, fazpassId, key); ok {
+		if entry.Error != nil {
+			apiErr := *entry.Error
+			return &Data{}, &apiErr
+		}
+		if entry.Data != nil {
+			return cloneData(entry.Data), nil
+		}
+	}
+	generation := f.removals.begin(fazpassId)
+	data, err := validate()
+	f.removals.end(fazpassId, generation, func() {
+		apiErr := &APIError{}
+		switch {
+		case err == nil:
+			f.cache.Cache.Set(ctx, fazpassId, key, &CacheEntry{Data: cloneData(data)}, f.cache.TTL)
+		case f.cache.NegativeTTL > 0 && DeviceRejected(err) && errors.As(err, &apiErr):
+			cached := *apiErr
+			f.cache.Cache.Set(ctx, fazpassId, key, &CacheEntry{Error: &cached}, f.cache.NegativeTTL)
+		}
+	})
+	return data, err
+}
+
+// invalidate removes the cached results of fazpassId, including those of
+// the validations in progress.
+func (f *Fazpass) invalidate(ctx context.Context, fazpassId string) {
+	if f.cache == nil {
+		return
+	}
+	f.removals.remove(fazpassId)
+	f.cache.Cache.Invalidate(ctx, fazpassId)
+}
+
+// removals tracks the devices being validated, so a validation which started
+// before the cache of its device was invalidated does not store its stale
+// result afterwards.
+type removals struct {
+	mu      sync.Mutex
+	devices map[string]*removal
+}
+
+// removal counts the removals of a device while it is being validated.
+type removal struct {
+	validations int
+
+	mu         sync.Mutex
+	generation uint64
+}
+
+// begin registers a validation of fazpassId and returns its generation.
+func (r *removals) begin(fazpassId string) uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	device, ok := r.devices[fazpassId]
+	if !ok {
+		device = &removal{}
+		r.devices[fazpassId] = device
+	}
+	device.validations++
+	device.mu.Lock()
+	defer device.mu.Unlock()
+	return device.generation
+}
+
+// end runs store unless fazpassId was removed since begin returned
+// generation, then unregisters the validation.
+func (r *removals) end(fazpassId string, generation uint64, store func()) {
+	r.mu.Lock()
+	device := r.devices[fazpassId]
+	r.mu.Unlock()
+
+	device.mu.Lock()
+	if device.generation == generation {
+		store()
+	}
+	device.mu.Unlock()
+
+	r.mu.Lock()
+	device.validations--
+	if device.validations == 0 {
+		delete(r.devices, fazpassId)
+	}
+	r.mu.Unlock()
+}
+
+// remove makes the validations of fazpassId in progress stale, it must be
+// called before invalidating the cache.
+func (r *removals) remove(fazpassId string) {
+	r.mu.Lock()
+	device, ok := r.devices[fazpassId]
+	r.mu.Unlock()
+	if ok {
+		device.mu.Lock()
+		device.generation++
+		device.mu.Unlock()
+	}
+}
+
+// LRUCache is an in-memory Cache evicting the least recently used entry
+// once full.
+type LRUCache struct {
+	maxEntries int
+	now        func() time.Time
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]map[string]*list.Element
+}
+
+type lruEntry struct {
+	fazpassId string
+	key       string
+	entry     *CacheEntry
+	expires   time.Time
+}
+
+// NewLRUCache returns a cache holding at most maxEntries entries.
+func NewLRUCache(maxEntries int) *LRUCache {
+	return &LRUCache{
+		maxEntries: maxEntries,
+		now:        time.Now,
+		order:      list.New(),
+		entries:    map[string]map[string]*list.Element{},
+	}
+}
+
+func (c *LRUCache) Get(ctx context.Context, fazpassId string, key string) (*CacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[fazpassId][key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*lruEntry)
+	if !c.now().Before(entry.expires) {
+		c.remove(element)
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return entry.entry, true
+}
+
+func (c *LRUCache) Set(ctx context.Context, fazpassId string, key string, entry *CacheEntry, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expires := c.now().Add(ttl)
+	if element, ok := c.entries[fazpassId][key]; ok {
+		cached := element.Value.(*lruEntry)
+		cached.entry, cached.expires = entry, expires
+		c.order.MoveToFront(element)
+		return
+	}
+	if c.entries[fazpassId] == nil {
+		c.entries[fazpassId] = map[string]*list.Element{}
+	}
+	c.entries[fazpassId][key] = c.order.PushFront(&lruEntry{fazpassId: fazpassId, key: key, entry: entry, expires: expires})
+	for c.maxEntries > 0 && c.order.Len() > c.maxEntries {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *LRUCache) Invalidate(ctx context.Context, fazpassId string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, element := range c.entries[fazpassId] {
+		c.remove(element)
+	}
+}
+
+// Len returns the number of entries, expired ones included.
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// remove removes element, c.mu must be held.
+func (c *LRUCache) remove(element *list.Element) {
+	entry := element.Value.(*lruEntry)
+	c.order.Remove(element)
+	delete(c.entries[entry.fazpassId], entry.key)
+	if len(c.entries[entry.fazpassId]) == 0 {
+		delete(c.entries, entry.fazpassId)
+	}
+}
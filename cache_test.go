@@ -0,0 +1,158 @@
+package fazpass
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestValidateCache(t *testing.T) {
+	t.Run("Hit", func(t *testing.T) {
+		flow := testFlow(&Data{SessionId: "1", Device: Device{SimSerial: []string{"1"}}}, nil)
+		f := testClient(t, flow, WithValidateCache(CacheConfig{}))
+		data, err := f.ValidateDevice("FAZPASS_ID", "KOALA_PANDA")
+		assert.Equal(t, err, nil)
+		data.Device.SimSerial[0] = "2"
+		data, err = f.ValidateDevice("FAZPASS_ID", "KOALA_PANDA")
+		assert.Equal(t, err, nil)
+		assert.Equal(t, data.SessionId, "1")
+		assert.Equal(t, data.Device.SimSerial, []string{"1"})
+		flow.AssertNumberOfCalls(t, "SendingData", 1)
+
+		f.ValidateDevice("FAZPASS_ID", "OTHER_DATA")
+		flow.AssertNumberOfCalls(t, "SendingData", 2)
+	})
+	t.Run("Negative", func(t *testing.T) {
+		flow := testFlow(&Data{}, &APIError{StatusCode: http.StatusNotFound, Code: "DEVICE_NOT_FOUND"})
+		f := testClient(t, flow, WithValidateCache(CacheConfig{}))
+		f.ValidateDevice("FAZPASS_ID", "KOALA_PANDA")
+		_, err := f.ValidateDevice("FAZPASS_ID", "KOALA_PANDA")
+		apiErr := &APIError{}
+		assert.True(t, errors.As(err, &apiErr))
+		assert.Equal(t, apiErr.Code, "DEVICE_NOT_FOUND")
+		flow.AssertNumberOfCalls(t, "SendingData", 1)
+	})
+	t.Run("Transient errors not cached", func(t *testing.T) {
+		flow := testFlow(&Data{}, &APIError{StatusCode: http.StatusTooManyRequests})
+		f := testClient(t, flow, WithValidateCache(CacheConfig{}))
+		f.ValidateDevice("FAZPASS_ID", "KOALA_PANDA")
+		f.ValidateDevice("FAZPASS_ID", "KOALA_PANDA")
+		flow.AssertNumberOfCalls(t, "SendingData", 2)
+	})
+	t.Run("Merchant errors not cached", func(t *testing.T) {
+		flow := testFlow(&Data{}, &APIError{StatusCode: http.StatusUnauthorized, Code: "UNAUTHORIZED"})
+		f := testClient(t, flow, WithValidateCache(CacheConfig{}))
+		f.ValidateDevice("FAZPASS_ID", "KOALA_PANDA")
+		f.ValidateDevice("FAZPASS_ID", "KOALA_PANDA")
+		flow.AssertNumberOfCalls(t, "SendingData", 2)
+	})
+	t.Run("Negative caching disabled", func(t *testing.T) {
+		flow := testFlow(&Data{}, &APIError{StatusCode: http.StatusNotFound})
+		f := testClient(t, flow, WithValidateCache(CacheConfig{NegativeTTL: -1}))
+		f.ValidateDevice("FAZPASS_ID", "KOALA_PANDA")
+		f.ValidateDevice("FAZPASS_ID", "KOALA_PANDA")
+		flow.AssertNumberOfCalls(t, "SendingData", 2)
+	})
+	t.Run("Invalidated by RemoveDevice", func(t *testing.T) {
+		flow := testFlow(&Data{SessionId: "1"}, nil)
+		f := testClient(t, flow, WithValidateCache(CacheConfig{}))
+		f.ValidateDevice("FAZPASS_ID", "KOALA_PANDA")
+		f.RemoveDevice("FAZPASS_ID", "KOALA_PANDA")
+		f.ValidateDevice("FAZPASS_ID", "KOALA_PANDA")
+		flow.AssertNumberOfCalls(t, "SendingData", 3)
+	})
+	t.Run("Invalidated by EnrollDevice", func(t *testing.T) {
+		flow := new(FlowMock)
+		flow.On("WrappingData", mock.Anything, mock.Anything).Return([]byte(""), nil)
+		resp, _ := httpmock.NewJsonResponse(200, &Transmission{})
+		flow.On("SendingData", mock.Anything, mock.Anything, mock.Anything).Return(resp, nil)
+		flow.On("ExtractingData", mock.Anything, mock.Anything, mock.Anything).Return(&Data{}, &APIError{StatusCode: http.StatusNotFound, Code: "DEVICE_NOT_FOUND"}).Once()
+		flow.On("ExtractingData", mock.Anything, mock.Anything, mock.Anything).Return(&Data{SessionId: "1", Device: Device{FazpassId: "FAZPASS_ID"}}, nil)
+		f := testClient(t, flow, WithValidateCache(CacheConfig{}))
+		f.ValidateDevice("FAZPASS_ID", "KOALA_PANDA")
+		_, err := f.EnrollDevice("anvarisy@gmail.com", "085811752000", "KOALA_PANDA")
+		assert.Equal(t, err, nil)
+		data, err := f.ValidateDevice("FAZPASS_ID", "KOALA_PANDA")
+		assert.Equal(t, err, nil)
+		assert.Equal(t, data.SessionId, "1")
+		flow.AssertNumberOfCalls(t, "SendingData", 3)
+	})
+	t.Run("Removed while validating", func(t *testing.T) {
+		flow := new(FlowMock)
+		var once sync.Once
+		started, release := make(chan struct{}), make(chan time.Time)
+		flow.On("WrappingData", mock.Anything, mock.Anything).Return([]byte(""), nil).
+			Run(func(mock.Arguments) { once.Do(func() { close(started) }) })
+		resp, _ := httpmock.NewJsonResponse(200, &Transmission{})
+		flow.On("SendingData", "http://localhost/validate", mock.Anything, mock.Anything).Return(resp, nil).WaitUntil(release)
+		flow.On("SendingData", "http://localhost/remove", mock.Anything, mock.Anything).Return(resp, nil)
+		flow.On("ExtractingData", mock.Anything, mock.Anything, mock.Anything).Return(&Data{SessionId: "1"}, nil)
+		f := testClient(t, flow, WithValidateCache(CacheConfig{}))
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			f.ValidateDevice("FAZPASS_ID", "KOALA_PANDA")
+		}()
+		<-started
+		_, err := f.RemoveDevice("FAZPASS_ID", "KOALA_PANDA")
+		assert.Equal(t, err, nil)
+		close(release)
+		<-done
+
+		f.ValidateDevice("FAZPASS_ID", "KOALA_PANDA")
+		flow.AssertNumberOfCalls(t, "SendingData", 3)
+		assert.Equal(t, len(f.removals.devices), 0)
+	})
+	t.Run("Invalid config", func(t *testing.T) {
+		_, err := New(WithValidateCache(CacheConfig{TTL: -time.Second}))
+		assert.Equal(t, err.Error(), "cache ttl and max entries cannot be negative")
+	})
+}
+
+func TestLRUCache(t *testing.T) {
+	ctx := context.Background()
+	entry := func(sessionId string) *CacheEntry {
+		return &CacheEntry{Data: &Data{SessionId: sessionId}}
+	}
+	t.Run("Eviction", func(t *testing.T) {
+		c := NewLRUCache(2)
+		c.Set(ctx, "A", "1", entry("A1"), time.Minute)
+		c.Set(ctx, "B", "1", entry("B1"), time.Minute)
+		c.Get(ctx, "A", "1")
+		c.Set(ctx, "C", "1", entry("C1"), time.Minute)
+		_, ok := c.Get(ctx, "B", "1")
+		assert.False(t, ok)
+		cached, ok := c.Get(ctx, "A", "1")
+		assert.True(t, ok)
+		assert.Equal(t, cached.Data.SessionId, "A1")
+		assert.Equal(t, c.Len(), 2)
+	})
+	t.Run("Expiry", func(t *testing.T) {
+		c := NewLRUCache(2)
+		now := time.Now()
+		c.now = func() time.Time { return now }
+		c.Set(ctx, "A", "1", entry("A1"), time.Minute)
+		now = now.Add(time.Minute)
+		_, ok := c.Get(ctx, "A", "1")
+		assert.False(t, ok)
+		assert.Equal(t, c.Len(), 0)
+	})
+	t.Run("Invalidate", func(t *testing.T) {
+		c := NewLRUCache(10)
+		c.Set(ctx, "A", "1", entry("A1"), time.Minute)
+		c.Set(ctx, "A", "2", entry("A2"), time.Minute)
+		c.Set(ctx, "B", "1", entry("B1"), time.Minute)
+		c.Invalidate(ctx, "A")
+		assert.Equal(t, c.Len(), 1)
+		_, ok := c.Get(ctx, "A", "2")
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,108 @@
+package fazpass
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// DefaultTimeout bounds a whole request, including reading the response body,
+// unless another timeout is set with WithTimeout.
+const DefaultTimeout = 30 * time.Second
+
+// defaultClient serves flows that were not built by NewFlow.
+var defaultClient = &http.Client{Timeout: DefaultTimeout}
+
+// transportOptions collects the options applied to the flow transport.
+type transportOptions struct {
+	roundTripper http.RoundTripper
+	proxy        func(*http.Request) (*url.URL, error)
+	tlsConfig    *tls.Config
+	maxIdleConns int
+}
+
+// DefaultWithClient returns the default flow sending every request through
+// client, which is used as is.
+func DefaultWithClient(client *http.Client) FlowContextInterface {
+	return NewFlow(withClient(client))
+}
+
+// WithTimeout sets the timeout of the flow http client, 0 means no timeout.
+func WithTimeout(timeout time.Duration) FlowOption {
+	return func(flow *Flow) {
+		flow.timeout = timeout
+	}
+}
+
+// WithTransport sets the round tripper of the flow http client. It takes
+// precedence over WithProxy, WithTLSConfig and WithMaxIdleConns.
+func WithTransport(roundTripper http.RoundTripper) FlowOption {
+	return func(flow *Flow) {
+		flow.transport.roundTripper = roundTripper
+	}
+}
+
+// WithProxy sets the proxy of the flow transport, see http.Transport.Proxy.
+func WithProxy(proxy func(*http.Request) (*url.URL, error)) FlowOption {
+	return func(flow *Flow) {
+		flow.transport.proxy = proxy
+	}
+}
+
+// WithTLSConfig sets the TLS configuration of the flow transport.
+func WithTLSConfig(config *tls.Config) FlowOption {
+	return func(flow *Flow) {
+		flow.transport.tlsConfig = config
+	}
+}
+
+// WithMaxIdleConns sets how many keep-alive connections the flow transport
+// keeps, both in total and per host.
+func WithMaxIdleConns(n int) FlowOption {
+	return func(flow *Flow) {
+		flow.transport.maxIdleConns = n
+	}
+}
+
+func withClient(client *http.Client) FlowOption {
+	return func(flow *Flow) {
+		flow.client = client
+	}
+}
+
+// newClient builds the http client of a flow from its options.
+func (flow *Flow) newClient() *http.Client {
+	return &http.Client{
+		Timeout:   flow.timeout,
+		Transport: flow.transport.build(),
+	}
+}
+
+// build returns the configured round tripper, or nil to use
+// http.DefaultTransport when nothing was customized.
+func (options transportOptions) build() http.RoundTripper {
+	if options.roundTripper != nil {
+		return options.roundTripper
+	}
+	if options.proxy == nil && options.tlsConfig == nil && options.maxIdleConns == 0 {
+		return nil
+	}
+	var transport *http.Transport
+	if defaultTransport, ok := http.DefaultTransport.(*http.Transport); ok {
+		transport = defaultTransport.Clone()
+	} else {
+		transport = &http.Transport{Proxy: http.ProxyFromEnvironment}
+	}
+	if options.proxy != nil {
+		transport.Proxy = options.proxy
+	}
+	if options.tlsConfig != nil {
+		transport.TLSClientConfig = options.tlsConfig
+	}
+	if options.maxIdleConns > 0 {
+		transport.MaxIdleConns = options.maxIdleConns
+		transport.MaxIdleConnsPerHost = options.maxIdleConns
+	}
+	return transport
+}
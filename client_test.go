@@ -0,0 +1,65 @@
+package fazpass
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type countingTransport struct {
+	calls int
+}
+
+func (c *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	c.calls++
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(100 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	t.Run("Default client", func(t *testing.T) {
+		f := Default().(*Flow)
+		assert.Equal(t, f.client.Timeout, DefaultTimeout)
+		assert.Nil(t, f.client.Transport)
+	})
+	t.Run("Client reused", func(t *testing.T) {
+		transport := &countingTransport{}
+		f := NewFlow(WithTransport(transport))
+		for i := 0; i < 2; i++ {
+			resp, err := f.SendingData(server.URL+"/check", []byte("{}"), "MERCHANT_KEY")
+			assert.Equal(t, err, nil)
+			resp.Body.Close()
+		}
+		assert.Equal(t, transport.calls, 2)
+	})
+	t.Run("Timeout", func(t *testing.T) {
+		f := NewFlow(WithTimeout(10 * time.Millisecond))
+		_, err := f.SendingData(server.URL+"/slow", []byte("{}"), "MERCHANT_KEY")
+		assert.True(t, errors.Is(err, ErrTransport))
+	})
+	t.Run("Transport options", func(t *testing.T) {
+		config := &tls.Config{MinVersion: tls.VersionTLS12}
+		f := NewFlow(WithTLSConfig(config), WithMaxIdleConns(7), WithProxy(http.ProxyFromEnvironment)).(*Flow)
+		transport := f.client.Transport.(*http.Transport)
+		assert.Equal(t, transport.TLSClientConfig, config)
+		assert.Equal(t, transport.MaxIdleConns, 7)
+		assert.Equal(t, transport.MaxIdleConnsPerHost, 7)
+		assert.NotNil(t, transport.Proxy)
+	})
+	t.Run("Default with client", func(t *testing.T) {
+		client := &http.Client{}
+		f := DefaultWithClient(client).(*Flow)
+		assert.Equal(t, f.client, client)
+	})
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+
+	fazpass "github.com/anvarisy/go-fazpass-sdk"
+)
+
+type command func(args []string, stdout io.Writer, stderr io.Writer) int
+
+var commands = map[string]command{
+	"check":    accountCommand("check", fazpass.FazpassContextInterface.CheckContext),
+	"enroll":   accountCommand("enroll", fazpass.FazpassContextInterface.EnrollDeviceContext),
+	"validate": deviceCommand("validate", fazpass.FazpassContextInterface.ValidateDeviceContext),
+	"remove":   deviceCommand("remove", fazpass.FazpassContextInterface.RemoveDeviceContext),
+	"keys":     keysCommand,
+	"policy":   policyCommand,
+}
+
+// accountCommand runs a call identified by email and phone.
+func accountCommand(name string, call func(fazpass.FazpassContextInterface, context.Context, string, string, string) (*fazpass.Data, error)) command {
+	return func(args []string, stdout io.Writer, stderr io.Writer) int {
+		var email, phone, encData string
+		cfg := &config{}
+		fs := newFlagSet(name, stderr, cfg)
+		fs.StringVar(&email, "email", "", "account email")
+		fs.StringVar(&phone, "phone", "", "account phone")
+		fs.StringVar(&encData, "data", "", "encrypted device data from the mobile SDK")
+		return execute(fs, args, cfg, stdout, stderr, func(ctx context.Context, f fazpass.FazpassContextInterface) (*fazpass.Data, error) {
+			return call(f, ctx, email, phone, encData)
+		})
+	}
+}
+
+// deviceCommand runs a call identified by fazpass id.
+func deviceCommand(name string, call func(fazpass.FazpassContextInterface, context.Context, string, string) (*fazpass.Data, error)) command {
+	return func(args []string, stdout io.Writer, stderr io.Writer) int {
+		var fazpassId, encData string
+		cfg := &config{}
+		fs := newFlagSet(name, stderr, cfg)
+		fs.StringVar(&fazpassId, "fazpass-id", "", "fazpass id of the device")
+		fs.StringVar(&encData, "data", "", "encrypted device data from the mobile SDK")
+		return execute(fs, args, cfg, stdout, stderr, func(ctx context.Context, f fazpass.FazpassContextInterface) (*fazpass.Data, error) {
+			return call(f, ctx, fazpassId, encData)
+		})
+	}
+}
+
+func newFlagSet(name string, stderr io.Writer, cfg *config) *flag.FlagSet {
+	fs := flag.NewFlagSet("fazpass "+name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	cfg.register(fs)
+	return fs
+}
+
+// execute parses args, builds the client, runs call and prints its result.
+func execute(fs *flag.FlagSet, args []string, cfg *config, stdout io.Writer, stderr io.Writer, call func(context.Context, fazpass.FazpassContextInterface) (*fazpass.Data, error)) int {
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	if err := cfg.resolve(); err != nil {
+		return fail(stderr, fs.Name(), err)
+	}
+	f, err := cfg.client()
+	if err != nil {
+		return fail(stderr, fs.Name(), err)
+	}
+	data, err := call(context.Background(), f)
+	if err != nil {
+		return fail(stderr, fs.Name(), err)
+	}
+	if err = printData(stdout, cfg.output, data); err != nil {
+		return fail(stderr, fs.Name(), err)
+	}
+	return exitOK
+}
+
+func fail(stderr io.Writer, name string, err error) int {
+	fmt.Fprintf(stderr, "%s: %v\n", name, err)
+	return exitCode(err)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	fazpass "github.com/anvarisy/go-fazpass-sdk"
+)
+
+var errConfig = errors.New("invalid configuration")
+
+// config holds the settings shared by every command.
+type config struct {
+	PrivateKey         string `json:"private_key"`
+	PrivateKeyPassword string `json:"private_key_password"`
+	PublicKey          string `json:"public_key"`
+	MerchantKey        string `json:"merchant_key"`
+	BaseUrl            string `json:"base_url"`
+	Encryption         string `json:"encryption"`
+
+	file    string
+	output  string
+	timeout time.Duration
+}
+
+// register adds the shared flags to fs.
+func (c *config) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.file, "config", "", "JSON config file (env FAZPASS_CONFIG)")
+	fs.StringVar(&c.PrivateKey, "private-key", "", "merchant private key file (env FAZPASS_PRIVATE_KEY)")
+	fs.StringVar(&c.PrivateKeyPassword, "private-key-password", "", "password of an encrypted private key (env FAZPASS_PRIVATE_KEY_PASSWORD)")
+	fs.StringVar(&c.PublicKey, "public-key", "", "Fazpass public key file (env FAZPASS_PUBLIC_KEY)")
+	fs.StringVar(&c.MerchantKey, "merchant-key", "", "merchant key (env FAZPASS_MERCHANT_KEY)")
+	fs.StringVar(&c.BaseUrl, "base-url", "", "Fazpass API base url (env FAZPASS_BASE_URL)")
+	fs.StringVar(&c.Encryption, "encryption", "", "encryption mode, rsa or hybrid (env FAZPASS_ENCRYPTION)")
+	fs.StringVar(&c.output, "output", "json", "output format: json, table or raw")
+	fs.DurationVar(&c.timeout, "timeout", fazpass.DefaultTimeout, "request timeout")
+}
+
+// resolve fills the settings left empty by flags from the environment, then
+// from the config file.
+func (c *config) resolve() error {
+	fromEnv := map[*string]string{
+		&c.PrivateKey:         "FAZPASS_PRIVATE_KEY",
+		&c.PrivateKeyPassword: "FAZPASS_PRIVATE_KEY_PASSWORD",
+		&c.PublicKey:          "FAZPASS_PUBLIC_KEY",
+		&c.MerchantKey:        "FAZPASS_MERCHANT_KEY",
+		&c.BaseUrl:            "FAZPASS_BASE_URL",
+		&c.Encryption:         "FAZPASS_ENCRYPTION",
+		&c.file:               "FAZPASS_CONFIG",
+	}
+	for field, name := range fromEnv {
+		if *field == "" {
+			*field = os.Getenv(name)
+		}
+	}
+	if c.file != "" {
+		content, err := os.ReadFile(c.file)
+		if err != nil {
+			return fmt.Errorf("%w: %v", errConfig, err)
+		}
+		file := &config{}
+		if err = json.Unmarshal(content, file); err != nil {
+			return fmt.Errorf("%w: %s: %v", errConfig, c.file, err)
+		}
+		fromFile := map[*string]string{
+			&c.PrivateKey:         file.PrivateKey,
+			&c.PrivateKeyPassword: file.PrivateKeyPassword,
+			&c.PublicKey:          file.PublicKey,
+			&c.MerchantKey:        file.MerchantKey,
+			&c.BaseUrl:            file.BaseUrl,
+			&c.Encryption:         file.Encryption,
+		}
+		for field, value := range fromFile {
+			if *field == "" {
+				*field = value
+			}
+		}
+	}
+	switch c.output {
+	case "json", "table", "raw":
+	default:
+		return fmt.Errorf("%w: unknown output %q", errConfig, c.output)
+	}
+	switch fazpass.EncryptionMode(c.Encryption) {
+	case "", fazpass.EncryptionRSA, fazpass.EncryptionHybrid:
+	default:
+		return fmt.Errorf("%w: unknown encryption %q", errConfig, c.Encryption)
+	}
+	return nil
+}
+
+// client builds the Fazpass client described by c.
+func (c *config) client() (fazpass.FazpassContextInterface, error) {
+	if c.PrivateKey == "" || c.PublicKey == "" {
+		return nil, fmt.Errorf("%w: private and public key files are required", errConfig)
+	}
+	priv, err := os.ReadFile(c.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", fazpass.ErrKeyNotFound, c.PrivateKey)
+	}
+	pub, err := os.ReadFile(c.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", fazpass.ErrKeyNotFound, c.PublicKey)
+	}
+	f, err := fazpass.New(
+		fazpass.WithEncryptedPrivateKeyPEM(priv, []byte(c.PrivateKeyPassword)),
+		fazpass.WithPublicKeyPEM(pub),
+		fazpass.WithMerchantKey(c.MerchantKey),
+		fazpass.WithBaseURL(c.BaseUrl),
+		fazpass.WithFlowOptions(
+			fazpass.WithTimeout(c.timeout),
+			fazpass.WithEncryptionMode(fazpass.EncryptionMode(c.Encryption)),
+		),
+	)
+	if err != nil {
+		if errors.Is(err, fazpass.ErrInvalidKey) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", errConfig, err)
+	}
+	return f, nil
+}
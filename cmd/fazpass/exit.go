@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	fazpass "github.com/anvarisy/go-fazpass-sdk"
+)
+
+// Exit codes, one per error category.
+const (
+	exitOK         = 0
+	exitError      = 1
+	exitUsage      = 2
+	exitConfig     = 3
+	exitValidation = 4
+	exitTransport  = 5
+	exitAPI        = 6
+	exitDecrypt    = 7
+)
+
+func exitCode(err error) int {
+	apiErr := &fazpass.APIError{}
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &apiErr):
+		return exitAPI
+	case errors.Is(err, fazpass.ErrValidation):
+		return exitValidation
+	case errors.Is(err, fazpass.ErrKeyNotFound), errors.Is(err, fazpass.ErrInvalidKey), errors.Is(err, errConfig):
+		return exitConfig
+	case errors.Is(err, fazpass.ErrTransport), errors.Is(err, fazpass.ErrCircuitOpen), errors.Is(err, context.DeadlineExceeded):
+		return exitTransport
+	case errors.Is(err, fazpass.ErrDecrypt):
+		return exitDecrypt
+	default:
+		return exitError
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	fazpass "github.com/anvarisy/go-fazpass-sdk"
+	"github.com/anvarisy/go-fazpass-sdk/utils"
+)
+
+const keysUsage = `usage: fazpass keys <command> [flags]
+
+commands:
+  generate   generate a merchant key pair
+  export     export the public key of a merchant private key
+`
+
+func keysCommand(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, keysUsage)
+		return exitUsage
+	}
+	switch args[0] {
+	case "generate":
+		return generateKeys(args[1:], stdout, stderr)
+	case "export":
+		return exportKey(args[1:], stdout, stderr)
+	default:
+		fmt.Fprintf(stderr, "fazpass keys: unknown command %q\n\n%s", args[0], keysUsage)
+		return exitUsage
+	}
+}
+
+// generateKeys writes a PKCS#1 private key and its PKIX public key, the one
+// to register with Fazpass.
+func generateKeys(args []string, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("fazpass keys generate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	bits := fs.Int("bits", utils.MinKeyBits, "key size in bits, at least 2048")
+	privOut := fs.String("out", "key.priv", "private key file")
+	pubOut := fs.String("public-out", "key.pub", "public key file")
+	force := fs.Bool("force", false, "overwrite existing files")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	if !*force {
+		for _, path := range []string{*privOut, *pubOut} {
+			if _, err := os.Stat(path); err == nil {
+				return fail(stderr, fs.Name(), fmt.Errorf("%w: %s already exists, use -force to overwrite it", errConfig, path))
+			}
+		}
+	}
+	priv, err := utils.GenerateKeyPair(*bits)
+	if err != nil {
+		return fail(stderr, fs.Name(), fmt.Errorf("%w: %v", fazpass.ErrInvalidKey, err))
+	}
+	pub, err := utils.PublicKeyToBytes(&priv.PublicKey)
+	if err != nil {
+		return fail(stderr, fs.Name(), err)
+	}
+	if err = os.WriteFile(*privOut, utils.PrivateKeyToBytes(priv), 0600); err != nil {
+		return fail(stderr, fs.Name(), err)
+	}
+	if err = os.WriteFile(*pubOut, pub, 0644); err != nil {
+		return fail(stderr, fs.Name(), err)
+	}
+	fingerprint, err := utils.Fingerprint(&priv.PublicKey)
+	if err != nil {
+		return fail(stderr, fs.Name(), err)
+	}
+	fmt.Fprintf(stdout, "private key: %s\npublic key:  %s\nfingerprint: %s\n", *privOut, *pubOut, fingerprint)
+	return exitOK
+}
+
+// exportKey prints, or writes, the public key of a private key.
+func exportKey(args []string, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("fazpass keys export", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	privPath := fs.String("private-key", "key.priv", "private key file")
+	password := fs.String("password", "", "password of an encrypted private key")
+	pubOut := fs.String("out", "", "public key file, standard output when empty")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	content, err := os.ReadFile(*privPath)
+	if err != nil {
+		return fail(stderr, fs.Name(), fmt.Errorf("%w: %s", fazpass.ErrKeyNotFound, *privPath))
+	}
+	priv, err := utils.ParsePrivateKey(content, []byte(*password))
+	if err != nil {
+		return fail(stderr, fs.Name(), fmt.Errorf("%w: %v", fazpass.ErrInvalidKey, err))
+	}
+	pub, err := utils.PublicKeyToBytes(&priv.PublicKey)
+	if err != nil {
+		return fail(stderr, fs.Name(), err)
+	}
+	fingerprint, err := utils.Fingerprint(&priv.PublicKey)
+	if err != nil {
+		return fail(stderr, fs.Name(), err)
+	}
+	if *pubOut == "" {
+		stdout.Write(pub)
+		fmt.Fprintf(stderr, "fingerprint: %s\n", fingerprint)
+		return exitOK
+	}
+	if err = os.WriteFile(*pubOut, pub, 0644); err != nil {
+		return fail(stderr, fs.Name(), err)
+	}
+	fmt.Fprintf(stdout, "public key:  %s\nfingerprint: %s\n", *pubOut, fingerprint)
+	return exitOK
+}
+
+// parse parses args into fs, returning the exit code when the command should stop.
+func parse(fs *flag.FlagSet, args []string) (int, bool) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK, false
+		}
+		return exitUsage, false
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(fs.Output(), "%s: unexpected arguments %v\n", fs.Name(), fs.Args())
+		return exitUsage, false
+	}
+	return exitOK, true
+}
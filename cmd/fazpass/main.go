@@ -0,0 +1,56 @@
+// Command fazpass calls the Fazpass API from the command line.
+//
+// Usage:
+//
+//	fazpass check    -email EMAIL -phone PHONE -data ENC_DATA [flags]
+//	fazpass enroll   -email EMAIL -phone PHONE -data ENC_DATA [flags]
+//	fazpass validate -fazpass-id ID -data ENC_DATA [flags]
+//	fazpass remove   -fazpass-id ID -data ENC_DATA [flags]
+//	fazpass keys generate [-bits 2048] [-out key.priv] [-public-out key.pub]
+//	fazpass keys export -private-key key.priv [-out key.pub]
+//	fazpass policy backtest -policy new.yaml -input checks.jsonl [-baseline current.yaml]
+//
+// Keys, merchant key and base url are read from flags, then FAZPASS_*
+// environment variables, then the JSON file given by -config or
+// FAZPASS_CONFIG. The decrypted data is printed as json, table or raw.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+const usage = `usage: fazpass <command> [flags]
+
+commands:
+  check      check a device by email and phone
+  enroll     enroll a device for an email and phone
+  validate   validate an enrolled device by fazpass id
+  remove     remove an enrolled device by fazpass id
+  keys       generate and export merchant keys
+  policy     backtest risk policies against recorded checks
+
+run "fazpass <command> -h" for the flags of a command.
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+	command, ok := commands[args[0]]
+	if !ok {
+		if args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+			fmt.Fprint(stdout, usage)
+			return exitOK
+		}
+		fmt.Fprintf(stderr, "fazpass: unknown command %q\n\n%s", args[0], usage)
+		return exitUsage
+	}
+	return command(args[1:], stdout, stderr)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	fazpass "github.com/anvarisy/go-fazpass-sdk"
+	"github.com/anvarisy/go-fazpass-sdk/fazpasstest"
+	"github.com/anvarisy/go-fazpass-sdk/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeKeys(t *testing.T, privKey *rsa.PrivateKey, pubKey *rsa.PublicKey) (string, string) {
+	dir := t.TempDir()
+	privPath := filepath.Join(dir, "key.priv")
+	pubPath := filepath.Join(dir, "key.pub")
+	os.WriteFile(privPath, utils.PrivateKeyToBytes(privKey), 0600)
+	pub, _ := utils.PublicKeyToBytes(pubKey)
+	os.WriteFile(pubPath, pub, 0644)
+	return privPath, pubPath
+}
+
+func TestRun(t *testing.T) {
+	server := fazpasstest.NewServer()
+	defer server.Close()
+	privPath, pubPath := writeKeys(t, server.ClientPrivateKey, server.ServerPublicKey)
+	t.Setenv("FAZPASS_MERCHANT_KEY", fazpasstest.MerchantKey)
+	t.Setenv("FAZPASS_BASE_URL", server.URL)
+	keyFlags := []string{"-private-key", privPath, "-public-key", pubPath}
+
+	t.Run("Check as json", func(t *testing.T) {
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		code := run(append([]string{"check", "-email", "anvarisy@gmail.com", "-phone", "085811752000", "-data", "KOALA_PANDA"}, keyFlags...), stdout, stderr)
+		assert.Equal(t, code, exitOK, stderr.String())
+		data := &fazpass.Data{}
+		assert.Equal(t, json.Unmarshal(stdout.Bytes(), data), nil)
+		assert.NotEqual(t, data.SessionId, "")
+	})
+	t.Run("Enroll as table", func(t *testing.T) {
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		code := run(append([]string{"enroll", "-email", "anvarisy@gmail.com", "-phone", "085811752000", "-data", "KOALA_PANDA", "-output", "table"}, keyFlags...), stdout, stderr)
+		assert.Equal(t, code, exitOK, stderr.String())
+		id, _ := server.FazpassId("anvarisy@gmail.com", "085811752000")
+		assert.Contains(t, stdout.String(), "FAZPASS ID")
+		assert.Contains(t, stdout.String(), id)
+	})
+	t.Run("Config file", func(t *testing.T) {
+		configPath := filepath.Join(t.TempDir(), "fazpass.json")
+		content, _ := json.Marshal(map[string]string{"private_key": privPath, "public_key": pubPath})
+		os.WriteFile(configPath, content, 0600)
+		id, _ := server.FazpassId("anvarisy@gmail.com", "085811752000")
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		code := run([]string{"validate", "-config", configPath, "-fazpass-id", id, "-data", "KOALA_PANDA", "-output", "raw"}, stdout, stderr)
+		assert.Equal(t, code, exitOK, stderr.String())
+		assert.Equal(t, strings.Count(stdout.String(), "\n"), 1)
+	})
+	t.Run("API error", func(t *testing.T) {
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		code := run(append([]string{"remove", "-fazpass-id", "UNKNOWN", "-data", "KOALA_PANDA"}, keyFlags...), stdout, stderr)
+		assert.Equal(t, code, exitAPI)
+		assert.Contains(t, stderr.String(), "DEVICE_NOT_FOUND")
+	})
+	t.Run("Validation error", func(t *testing.T) {
+		code := run(append([]string{"check", "-phone", "085811752000", "-data", "KOALA_PANDA"}, keyFlags...), &bytes.Buffer{}, &bytes.Buffer{})
+		assert.Equal(t, code, exitValidation)
+	})
+	t.Run("Key not found", func(t *testing.T) {
+		code := run([]string{"check", "-private-key", "key", "-public-key", pubPath}, &bytes.Buffer{}, &bytes.Buffer{})
+		assert.Equal(t, code, exitConfig)
+	})
+	t.Run("Unknown command", func(t *testing.T) {
+		code := run([]string{"KOALA_PANDA"}, &bytes.Buffer{}, &bytes.Buffer{})
+		assert.Equal(t, code, exitUsage)
+	})
+}
+
+func TestKeys(t *testing.T) {
+	dir := t.TempDir()
+	privPath := filepath.Join(dir, "key.priv")
+	pubPath := filepath.Join(dir, "key.pub")
+
+	t.Run("Generate", func(t *testing.T) {
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		code := run([]string{"keys", "generate", "-out", privPath, "-public-out", pubPath}, stdout, stderr)
+		assert.Equal(t, code, exitOK, stderr.String())
+		assert.Contains(t, stdout.String(), "fingerprint: SHA256:")
+
+		priv, _ := os.ReadFile(privPath)
+		privKey, err := utils.BytesToPrivateKey(priv)
+		assert.Equal(t, err, nil)
+		pub, _ := os.ReadFile(pubPath)
+		pubKey, err := utils.BytesToPublicKey(pub)
+		assert.Equal(t, err, nil)
+		assert.True(t, pubKey.Equal(&privKey.PublicKey))
+	})
+	t.Run("Refuse to overwrite", func(t *testing.T) {
+		code := run([]string{"keys", "generate", "-out", privPath, "-public-out", pubPath}, &bytes.Buffer{}, &bytes.Buffer{})
+		assert.Equal(t, code, exitConfig)
+	})
+	t.Run("Refuse small keys", func(t *testing.T) {
+		code := run([]string{"keys", "generate", "-bits", "1024", "-out", filepath.Join(dir, "small.priv"), "-public-out", filepath.Join(dir, "small.pub")}, &bytes.Buffer{}, &bytes.Buffer{})
+		assert.Equal(t, code, exitConfig)
+	})
+	t.Run("Export", func(t *testing.T) {
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		code := run([]string{"keys", "export", "-private-key", privPath}, stdout, stderr)
+		assert.Equal(t, code, exitOK, stderr.String())
+		pub, _ := os.ReadFile(pubPath)
+		assert.Equal(t, stdout.String(), string(pub))
+		assert.Contains(t, stderr.String(), "fingerprint: SHA256:")
+	})
+}
+
+func TestPolicy(t *testing.T) {
+	dir := t.TempDir()
+	policyPath := filepath.Join(dir, "policy.yaml")
+	os.WriteFile(policyPath, []byte("version: 1\nrules:\n  - {reason: vpn, decision: deny, when: {vpn: true}}\n"), 0644)
+	inputPath := filepath.Join(dir, "checks.jsonl")
+	os.WriteFile(inputPath, []byte(`{"session_id":"1","device":{"score":1}}
+{"session_id":"2","device":{"score":1,"is_vpn":true}}
+{"session_id":"3","device":{"score":1,"is_vpn":true}}
+{"session_id":"4","device":{"score":1,"is_emulator":true}}
+`), 0644)
+
+	t.Run("Backtest", func(t *testing.T) {
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		code := run([]string{"policy", "backtest", "--policy", policyPath, "--input", inputPath}, stdout, stderr)
+		assert.Equal(t, code, exitOK, stderr.String())
+		assert.Contains(t, stdout.String(), "changed  3  75.0%")
+		assert.Contains(t, stdout.String(), "deny       1         2          +1")
+		assert.Contains(t, stdout.String(), "challenge -> deny  2")
+		assert.Contains(t, stdout.String(), "deny -> allow      1")
+		assert.Contains(t, stdout.String(), "vpn     2        50.0%")
+	})
+	t.Run("Backtest as json", func(t *testing.T) {
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		code := run([]string{"policy", "backtest", "-policy", policyPath, "-input", inputPath, "-baseline", policyPath, "-output", "json"}, stdout, stderr)
+		assert.Equal(t, code, exitOK, stderr.String())
+		report := map[string]interface{}{}
+		assert.Equal(t, json.Unmarshal(stdout.Bytes(), &report), nil)
+		assert.Equal(t, report["changed"], float64(0))
+		assert.Equal(t, report["decisions"], map[string]interface{}{"allow": float64(2), "deny": float64(2)})
+	})
+	t.Run("Invalid policy", func(t *testing.T) {
+		stderr := &bytes.Buffer{}
+		code := run([]string{"policy", "backtest", "-policy", inputPath, "-input", inputPath}, &bytes.Buffer{}, stderr)
+		assert.Equal(t, code, exitConfig)
+		assert.Contains(t, stderr.String(), "line 1")
+	})
+	t.Run("Missing policy", func(t *testing.T) {
+		code := run([]string{"policy", "backtest", "-input", inputPath}, &bytes.Buffer{}, &bytes.Buffer{})
+		assert.Equal(t, code, exitConfig)
+	})
+}
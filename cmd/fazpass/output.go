@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	fazpass "github.com/anvarisy/go-fazpass-sdk"
+)
+
+// printData writes data to w in the given output format.
+func printData(w io.Writer, output string, data *fazpass.Data) error {
+	switch output {
+	case "raw":
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(encoded))
+		return err
+	case "table":
+		return printTable(w, data)
+	default:
+		encoded, err := json.MarshalIndent(data, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(encoded))
+		return err
+	}
+}
+
+func printTable(w io.Writer, data *fazpass.Data) error {
+	timestamp := ""
+	if data.TimeStamp != nil {
+		timestamp = data.TimeStamp.Format(time.RFC3339)
+	}
+	device := data.Device
+	rows := [][2]string{
+		{"SESSION ID", data.SessionId},
+		{"TIMESTAMP", timestamp},
+		{"FAZPASS ID", device.FazpassId},
+		{"SCORE", fmt.Sprint(device.Score)},
+		{"PLATFORM", device.Platform},
+		{"NAME", device.Name},
+		{"CPU", device.CPU},
+		{"TIMEZONE", device.Timezone},
+		{"SIM SERIAL", strings.Join(device.SimSerial, ",")},
+		{"GEOLOCATION", fmt.Sprintf("%v,%v", device.Geolocation.Latitude, device.Geolocation.Longitude)},
+		{"ROOTED", fmt.Sprint(device.IsRooted)},
+		{"EMULATOR", fmt.Sprint(device.IsEmulator)},
+		{"GPS SPOOF", fmt.Sprint(device.IsGpsSpoof)},
+		{"APP TAMPER", fmt.Sprint(device.IsAppTemper)},
+		{"VPN", fmt.Sprint(device.IsVpn)},
+		{"SCREEN SHARING", fmt.Sprint(device.IsScreenSharing)},
+		{"DEBUGGING", fmt.Sprint(device.IsDebuging)},
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, row := range rows {
+		fmt.Fprintf(tw, "%s\t%s\n", row[0], row[1])
+	}
+	return tw.Flush()
+}
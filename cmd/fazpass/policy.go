@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	fazpass "github.com/anvarisy/go-fazpass-sdk"
+	"github.com/anvarisy/go-fazpass-sdk/policy"
+)
+
+const policyUsage = `usage: fazpass policy <command> [flags]
+
+commands:
+  backtest   replay recorded device checks against a policy
+`
+
+var decisions = []fazpass.Decision{fazpass.Allow, fazpass.Challenge, fazpass.Deny}
+
+func policyCommand(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, policyUsage)
+		return exitUsage
+	}
+	switch args[0] {
+	case "backtest":
+		return backtest(args[1:], stdout, stderr)
+	default:
+		fmt.Fprintf(stderr, "fazpass policy: unknown command %q\n\n%s", args[0], policyUsage)
+		return exitUsage
+	}
+}
+
+// backtestOutput is the json report of backtest.
+type backtestOutput struct {
+	*policy.Report
+	Changes    []changeCount        `json:"changes"`
+	TopReasons []policy.ReasonCount `json:"top_reasons"`
+}
+
+type changeCount struct {
+	policy.Change
+	Count int `json:"count"`
+}
+
+// backtest evaluates recorded Data, one JSON object per line, with a
+// candidate policy and compares its decisions to a baseline policy.
+func backtest(args []string, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("fazpass policy backtest", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	policyPath := fs.String("policy", "", "candidate policy file, YAML or JSON")
+	baselinePath := fs.String("baseline", "", "baseline policy file, the default policy when empty")
+	inputPath := fs.String("input", "-", "recorded data as JSON lines, standard input when -")
+	top := fs.Int("top", 10, "number of reason codes to list")
+	output := fs.String("output", "text", "output format: text or json")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	if *policyPath == "" {
+		return fail(stderr, fs.Name(), fmt.Errorf("%w: -policy is required", errConfig))
+	}
+	if *output != "text" && *output != "json" {
+		return fail(stderr, fs.Name(), fmt.Errorf("%w: unknown output %q", errConfig, *output))
+	}
+	candidate, err := policy.LoadFile(*policyPath)
+	if err != nil {
+		return fail(stderr, fs.Name(), fmt.Errorf("%w: %v", errConfig, err))
+	}
+	baseline := policy.Default()
+	if *baselinePath != "" {
+		if baseline, err = policy.LoadFile(*baselinePath); err != nil {
+			return fail(stderr, fs.Name(), fmt.Errorf("%w: %v", errConfig, err))
+		}
+	}
+	input := io.Reader(os.Stdin)
+	if *inputPath != "-" {
+		file, err := os.Open(*inputPath)
+		if err != nil {
+			return fail(stderr, fs.Name(), fmt.Errorf("%w: %v", errConfig, err))
+		}
+		defer file.Close()
+		input = file
+	}
+	report, err := policy.Backtest(input, candidate, baseline)
+	if err != nil {
+		return fail(stderr, fs.Name(), fmt.Errorf("%s: %w", *inputPath, err))
+	}
+	if *output == "json" {
+		err = printBacktestJSON(stdout, report, *top)
+	} else {
+		err = printBacktest(stdout, report, *top)
+	}
+	if err != nil {
+		return fail(stderr, fs.Name(), err)
+	}
+	return exitOK
+}
+
+// changes lists the changed decisions, most frequent first.
+func changes(report *policy.Report) []changeCount {
+	counts := make([]changeCount, 0, len(report.Changes))
+	for change, count := range report.Changes {
+		counts = append(counts, changeCount{Change: change, Count: count})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		if counts[i].From != counts[j].From {
+			return counts[i].From < counts[j].From
+		}
+		return counts[i].To < counts[j].To
+	})
+	return counts
+}
+
+func printBacktestJSON(w io.Writer, report *policy.Report, top int) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(backtestOutput{Report: report, Changes: changes(report), TopReasons: report.TopReasons(top)})
+}
+
+func printBacktest(w io.Writer, report *policy.Report, top int) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "records\t%d\n", report.Records)
+	fmt.Fprintf(tw, "changed\t%d\t%s\n", report.Changed, percent(report.Changed, report.Records))
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "DECISION\tBASELINE\tCANDIDATE\tDELTA")
+	for _, decision := range decisions {
+		baseline, candidate := report.BaselineDecisions[decision], report.Decisions[decision]
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%+d\n", decision, baseline, candidate, candidate-baseline)
+	}
+	if counts := changes(report); len(counts) > 0 {
+		fmt.Fprintln(tw)
+		fmt.Fprintln(tw, "CHANGE\tRECORDS")
+		for _, count := range counts {
+			fmt.Fprintf(tw, "%s -> %s\t%d\n", count.From, count.To, count.Count)
+		}
+	}
+	if reasons := report.TopReasons(top); len(reasons) > 0 {
+		fmt.Fprintln(tw)
+		fmt.Fprintln(tw, "REASON\tRECORDS\tSHARE")
+		for _, reason := range reasons {
+			fmt.Fprintf(tw, "%s\t%d\t%s\n", reason.Reason, reason.Count, percent(reason.Count, report.Records))
+		}
+	}
+	return tw.Flush()
+}
+
+func percent(count int, total int) string {
+	if total == 0 {
+		return "0.0%"
+	}
+	return fmt.Sprintf("%.1f%%", float64(count)*100/float64(total))
+}
@@ -0,0 +1,164 @@
+package fazpass
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CredentialProvider supplies the merchant key, it is consulted on every
+// request so the key can be rotated without recreating the client.
+type CredentialProvider interface {
+	MerchantKey(ctx context.Context) (string, error)
+}
+
+// CredentialFunc adapts a function to CredentialProvider, e.g. to fetch the
+// merchant key from a vault.
+type CredentialFunc func(ctx context.Context) (string, error)
+
+func (fn CredentialFunc) MerchantKey(ctx context.Context) (string, error) {
+	return fn(ctx)
+}
+
+// StaticCredentials always returns merchantKey.
+func StaticCredentials(merchantKey string) CredentialProvider {
+	return CredentialFunc(func(ctx context.Context) (string, error) {
+		if merchantKey == "" {
+			return "", errors.New("merchant key is empty")
+		}
+		return merchantKey, nil
+	})
+}
+
+// EnvCredentials reads the merchant key from the environment variable name.
+func EnvCredentials(name string) CredentialProvider {
+	return CredentialFunc(func(ctx context.Context) (string, error) {
+		merchantKey := strings.TrimSpace(os.Getenv(name))
+		if merchantKey == "" {
+			return "", fmt.Errorf("environment variable %s is empty", name)
+		}
+		return merchantKey, nil
+	})
+}
+
+// FileCredentials reads the merchant key from the file at path on every
+// call, surrounding whitespace is ignored.
+func FileCredentials(path string) CredentialProvider {
+	return CredentialFunc(func(ctx context.Context) (string, error) {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("cannot read merchant key file %s", path)
+		}
+		merchantKey := string(bytes.TrimSpace(content))
+		if merchantKey == "" {
+			return "", fmt.Errorf("merchant key file %s is empty", path)
+		}
+		return merchantKey, nil
+	})
+}
+
+// credentialsRetry is how long CachingCredentials keeps using its previous
+// key after a failed refresh before trying again, at most its ttl.
+const credentialsRetry = 5 * time.Second
+
+// CachingCredentials caches the merchant key of provider for ttl. When a
+// refresh fails the previous key keeps being used, and the refresh is retried
+// a few seconds later, so a flaky vault does not fail requests. Requests do
+// not wait for a refresh when a previous key is cached.
+func CachingCredentials(provider CredentialProvider, ttl time.Duration) CredentialProvider {
+	return &cachingCredentials{provider: provider, ttl: ttl, now: time.Now}
+}
+
+type cachingCredentials struct {
+	provider CredentialProvider
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu          sync.Mutex
+	merchantKey string
+	expiresAt   time.Time
+	// refreshing is closed once the refresh in flight, if any, is over.
+	refreshing chan struct{}
+}
+
+func (c *cachingCredentials) MerchantKey(ctx context.Context) (string, error) {
+	for {
+		c.mu.Lock()
+		if c.merchantKey != "" && c.now().Before(c.expiresAt) {
+			merchantKey := c.merchantKey
+			c.mu.Unlock()
+			return merchantKey, nil
+		}
+		if c.refreshing == nil {
+			c.refreshing = make(chan struct{})
+			c.mu.Unlock()
+			return c.refresh(ctx)
+		}
+		if c.merchantKey != "" {
+			merchantKey := c.merchantKey
+			c.mu.Unlock()
+			return merchantKey, nil
+		}
+		refreshing := c.refreshing
+		c.mu.Unlock()
+		select {
+		case <-refreshing:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+// refresh gets the merchant key from the provider, c.refreshing being set.
+func (c *cachingCredentials) refresh(ctx context.Context) (string, error) {
+	merchantKey, err := c.provider.MerchantKey(ctx)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	close(c.refreshing)
+	c.refreshing = nil
+	if err != nil {
+		if c.merchantKey == "" {
+			return "", err
+		}
+		retry := credentialsRetry
+		if c.ttl < retry {
+			retry = c.ttl
+		}
+		c.expiresAt = c.now().Add(retry)
+		return c.merchantKey, nil
+	}
+	c.merchantKey = merchantKey
+	c.expiresAt = c.now().Add(c.ttl)
+	return merchantKey, nil
+}
+
+// redacted is the placeholder of the merchant key in error strings.
+const redacted = "[REDACTED]"
+
+// redactedError hides the merchant key from the message of err, which stays
+// reachable through errors.Is and errors.As.
+type redactedError struct {
+	err         error
+	merchantKey string
+}
+
+func (e *redactedError) Error() string {
+	return strings.ReplaceAll(e.err.Error(), e.merchantKey, redacted)
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// redact wraps err when its message holds merchantKey.
+func redact(err error, merchantKey string) error {
+	if err == nil || merchantKey == "" || !strings.Contains(err.Error(), merchantKey) {
+		return err
+	}
+	return &redactedError{err: err, merchantKey: merchantKey}
+}
@@ -0,0 +1,148 @@
+package fazpass
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCredentials(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Static", func(t *testing.T) {
+		merchantKey, err := StaticCredentials("MERCHANT_KEY").MerchantKey(ctx)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, merchantKey, "MERCHANT_KEY")
+	})
+	t.Run("Environment", func(t *testing.T) {
+		t.Setenv("FAZPASS_TEST_MERCHANT_KEY", "MERCHANT_KEY")
+		merchantKey, _ := EnvCredentials("FAZPASS_TEST_MERCHANT_KEY").MerchantKey(ctx)
+		assert.Equal(t, merchantKey, "MERCHANT_KEY")
+		_, err := EnvCredentials("FAZPASS_TEST_MISSING").MerchantKey(ctx)
+		assert.NotEqual(t, err, nil)
+	})
+	t.Run("File", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "merchant_key")
+		os.WriteFile(path, []byte("MERCHANT_KEY\n"), 0600)
+		provider := FileCredentials(path)
+		merchantKey, _ := provider.MerchantKey(ctx)
+		assert.Equal(t, merchantKey, "MERCHANT_KEY")
+		os.WriteFile(path, []byte("NEW_MERCHANT_KEY"), 0600)
+		merchantKey, _ = provider.MerchantKey(ctx)
+		assert.Equal(t, merchantKey, "NEW_MERCHANT_KEY")
+	})
+	t.Run("Caching", func(t *testing.T) {
+		calls := 0
+		var failure error
+		provider := CachingCredentials(CredentialFunc(func(ctx context.Context) (string, error) {
+			calls++
+			return fmt.Sprintf("MERCHANT_KEY_%d", calls), failure
+		}), time.Minute).(*cachingCredentials)
+		now := time.Now()
+		provider.now = func() time.Time { return now }
+
+		merchantKey, _ := provider.MerchantKey(ctx)
+		assert.Equal(t, merchantKey, "MERCHANT_KEY_1")
+		merchantKey, _ = provider.MerchantKey(ctx)
+		assert.Equal(t, merchantKey, "MERCHANT_KEY_1")
+
+		now = now.Add(time.Minute)
+		merchantKey, _ = provider.MerchantKey(ctx)
+		assert.Equal(t, merchantKey, "MERCHANT_KEY_2")
+
+		now = now.Add(time.Minute)
+		failure = errors.New("vault unavailable")
+		merchantKey, err := provider.MerchantKey(ctx)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, merchantKey, "MERCHANT_KEY_2")
+		assert.Equal(t, calls, 3)
+
+		provider.MerchantKey(ctx)
+		assert.Equal(t, calls, 3)
+		now = now.Add(credentialsRetry)
+		failure = nil
+		merchantKey, _ = provider.MerchantKey(ctx)
+		assert.Equal(t, merchantKey, "MERCHANT_KEY_4")
+	})
+	t.Run("Refresh does not block", func(t *testing.T) {
+		release := make(chan struct{})
+		refreshed := make(chan string)
+		calls := 0
+		provider := CachingCredentials(CredentialFunc(func(ctx context.Context) (string, error) {
+			calls++
+			if calls > 1 {
+				<-release
+			}
+			return fmt.Sprintf("MERCHANT_KEY_%d", calls), nil
+		}), time.Minute).(*cachingCredentials)
+		now := time.Now()
+		provider.now = func() time.Time { return now }
+		provider.MerchantKey(ctx)
+
+		now = now.Add(time.Minute)
+		go func() {
+			merchantKey, _ := provider.MerchantKey(ctx)
+			refreshed <- merchantKey
+		}()
+		for {
+			provider.mu.Lock()
+			started := provider.refreshing != nil
+			provider.mu.Unlock()
+			if started {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		merchantKey, _ := provider.MerchantKey(ctx)
+		assert.Equal(t, merchantKey, "MERCHANT_KEY_1")
+		close(release)
+		assert.Equal(t, <-refreshed, "MERCHANT_KEY_2")
+		merchantKey, _ = provider.MerchantKey(ctx)
+		assert.Equal(t, merchantKey, "MERCHANT_KEY_2")
+	})
+}
+
+func TestCredentialProvider(t *testing.T) {
+	priv, _ := os.ReadFile("key.priv")
+	pub, _ := os.ReadFile("key.pub")
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	t.Run("Merchant key per request", func(t *testing.T) {
+		merchantKeys := make(chan string, 1)
+		httpmock.RegisterResponder("POST", "http://localhost/check", func(r *http.Request) (*http.Response, error) {
+			merchantKeys <- r.Header.Get("Authorization")
+			return httpmock.NewStringResponse(http.StatusUnauthorized, `{"code":"UNAUTHORIZED"}`), nil
+		})
+		f, err := New(WithPrivateKeyPEM(priv), WithPublicKeyPEM(pub), WithBaseURL("http://localhost"),
+			WithCredentialProvider(StaticCredentials("VAULT_MERCHANT_KEY")))
+		assert.Equal(t, err, nil)
+		f.Check("anvarisy@gmail.com", "085811752000", "KOALA_PANDA")
+		assert.Equal(t, <-merchantKeys, "Bearer VAULT_MERCHANT_KEY")
+	})
+	t.Run("Provider failure", func(t *testing.T) {
+		f, _ := New(WithPrivateKeyPEM(priv), WithPublicKeyPEM(pub), WithBaseURL("http://localhost"),
+			WithCredentialProvider(EnvCredentials("FAZPASS_TEST_MISSING")))
+		_, err := f.Check("anvarisy@gmail.com", "085811752000", "KOALA_PANDA")
+		assert.True(t, errors.Is(err, ErrCredentials))
+	})
+	t.Run("Merchant key redacted", func(t *testing.T) {
+		httpmock.RegisterResponder("POST", "http://localhost/check",
+			httpmock.NewStringResponder(http.StatusUnauthorized, `{"code":"UNAUTHORIZED","message":"unknown key SECRET_MERCHANT_KEY"}`))
+		f, _ := New(WithPrivateKeyPEM(priv), WithPublicKeyPEM(pub), WithBaseURL("http://localhost"), WithMerchantKey("SECRET_MERCHANT_KEY"))
+		_, err := f.Check("anvarisy@gmail.com", "085811752000", "KOALA_PANDA")
+		assert.False(t, strings.Contains(err.Error(), "SECRET_MERCHANT_KEY"))
+		apiErr := &APIError{}
+		assert.True(t, errors.As(err, &apiErr))
+		assert.False(t, strings.Contains(fmt.Sprintf("%v %+v %#v", f, f, f), "SECRET_MERCHANT_KEY"))
+	})
+}
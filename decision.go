@@ -0,0 +1,103 @@
+package fazpass
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Decision is what to do with a device, from the least to the most severe.
+type Decision int
+
+const (
+	Allow Decision = iota
+	Challenge
+	Deny
+)
+
+func (d Decision) String() string {
+	switch d {
+	case Allow:
+		return "allow"
+	case Challenge:
+		return "challenge"
+	case Deny:
+		return "deny"
+	default:
+		return fmt.Sprintf("Decision(%d)", int(d))
+	}
+}
+
+// ParseDecision parses the name returned by Decision.String.
+func ParseDecision(name string) (Decision, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "allow":
+		return Allow, nil
+	case "challenge":
+		return Challenge, nil
+	case "deny":
+		return Deny, nil
+	default:
+		return Allow, fmt.Errorf("unknown decision %q", name)
+	}
+}
+
+func (d Decision) MarshalText() ([]byte, error) {
+	if d < Allow || d > Deny {
+		return nil, fmt.Errorf("unknown decision %d", int(d))
+	}
+	return []byte(d.String()), nil
+}
+
+func (d *Decision) UnmarshalText(text []byte) error {
+	decision, err := ParseDecision(string(text))
+	if err != nil {
+		return err
+	}
+	*d = decision
+	return nil
+}
+
+// Verdict is the outcome of evaluating a device, Reasons lists the codes of
+// the rules that led to the decision.
+type Verdict struct {
+	Decision Decision `json:"decision"`
+	Reasons  []string `json:"reasons,omitempty"`
+}
+
+// Evaluator decides what to do with the data returned by Fazpass, see the
+// policy package.
+type Evaluator interface {
+	Evaluate(data *Data) Verdict
+}
+
+// WithPolicy sets the enforced policy, see Fazpass.Policy.
+func WithPolicy(evaluator Evaluator) Option {
+	return func(f *Fazpass) error {
+		if evaluator == nil {
+			return errors.New("policy cannot be nil")
+		}
+		f.Policy = evaluator
+		return nil
+	}
+}
+
+// CheckAndDecide runs Check and evaluates its result with evaluator, or with
+// f.Policy when evaluator is nil. The verdict is only meaningful when the
+// error is nil.
+func (f *Fazpass) CheckAndDecide(ctx context.Context, email string, phone string, encData string, evaluator Evaluator) (*Data, Verdict, error) {
+	if evaluator == nil {
+		evaluator = f.Policy
+	}
+	if evaluator == nil {
+		return &Data{}, Verdict{}, errors.New("no policy to decide with")
+	}
+	data, err := f.check(ctx, email, phone, encData)
+	if err != nil {
+		return data, Verdict{}, err
+	}
+	verdict := evaluator.Evaluate(data)
+	f.shadow("/check", data, &verdict)
+	return data, verdict, nil
+}
@@ -0,0 +1,65 @@
+package fazpass
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type evaluatorFunc func(data *Data) Verdict
+
+func (e evaluatorFunc) Evaluate(data *Data) Verdict {
+	return e(data)
+}
+
+func TestDecision(t *testing.T) {
+	t.Run("Text", func(t *testing.T) {
+		marshalled, err := json.Marshal(Verdict{Decision: Challenge, Reasons: []string{"vpn"}})
+		assert.Equal(t, err, nil)
+		assert.Equal(t, string(marshalled), `{"decision":"challenge","reasons":["vpn"]}`)
+		verdict := Verdict{}
+		assert.Equal(t, json.Unmarshal([]byte(`{"decision":"deny"}`), &verdict), nil)
+		assert.Equal(t, verdict.Decision, Deny)
+		assert.NotEqual(t, json.Unmarshal([]byte(`{"decision":"block"}`), &verdict), nil)
+	})
+}
+
+func TestCheckAndDecide(t *testing.T) {
+	deny := evaluatorFunc(func(data *Data) Verdict {
+		return Verdict{Decision: Deny, Reasons: []string{data.SessionId}}
+	})
+	t.Run("Evaluated", func(t *testing.T) {
+		f := new(FlowMock)
+		client, _ := Initialize(f, "key.priv", "key.pub", "MERCHANT_KEY", "http://localhost:8080")
+		fazpass := client.(*Fazpass)
+		f.On("WrappingData", mock.Anything, mock.Anything).Return([]byte(""), nil)
+		resp, _ := httpmock.NewJsonResponse(200, &Transmission{})
+		f.On("SendingData", mock.Anything, mock.Anything, mock.Anything).Return(resp, nil)
+		f.On("ExtractingData", mock.Anything, mock.Anything, mock.Anything).Return(&Data{SessionId: "1"}, nil)
+		data, verdict, err := fazpass.CheckAndDecide(context.Background(), "anvarisy@gmail.com", "085811752000", "KOALA_PANDA", deny)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, data.SessionId, "1")
+		assert.Equal(t, verdict, Verdict{Decision: Deny, Reasons: []string{"1"}})
+	})
+	t.Run("Check failed", func(t *testing.T) {
+		f := new(FlowMock)
+		client, _ := Initialize(f, "key.priv", "key.pub", "MERCHANT_KEY", "http://localhost:8080")
+		fazpass := client.(*Fazpass)
+		f.On("WrappingData", mock.Anything, mock.Anything).Return([]byte(""), errors.New("wraping failed"))
+		_, verdict, err := fazpass.CheckAndDecide(context.Background(), "anvarisy@gmail.com", "085811752000", "KOALA_PANDA", deny)
+		assert.Equal(t, err.Error(), "wraping failed")
+		assert.Equal(t, verdict, Verdict{})
+	})
+	t.Run("No policy", func(t *testing.T) {
+		f := new(FlowMock)
+		client, _ := Initialize(f, "key.priv", "key.pub", "MERCHANT_KEY", "http://localhost:8080")
+		fazpass := client.(*Fazpass)
+		_, _, err := fazpass.CheckAndDecide(context.Background(), "anvarisy@gmail.com", "085811752000", "KOALA_PANDA", nil)
+		assert.Equal(t, err.Error(), "no policy to decide with")
+	})
+}
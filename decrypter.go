@@ -0,0 +1,59 @@
+package fazpass
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/anvarisy/go-fazpass-sdk/utils"
+)
+
+// FileDecrypter is a crypto.Decrypter reading its private key file on every
+// use, so the key is only held in memory while decrypting. It is the
+// reference for KMS or HSM backed decrypters, see WithDecrypter.
+type FileDecrypter struct {
+	path     string
+	password []byte
+	public   *rsa.PublicKey
+}
+
+// NewFileDecrypter checks the private key file at path and returns a
+// decrypter reading it. The password is only needed for an encrypted key.
+func NewFileDecrypter(path string, password []byte) (*FileDecrypter, error) {
+	d := &FileDecrypter{path: path, password: password}
+	key, err := d.load()
+	if err != nil {
+		return nil, err
+	}
+	d.public = &key.PublicKey
+	return d, nil
+}
+
+func (d *FileDecrypter) Public() crypto.PublicKey {
+	return d.public
+}
+
+func (d *FileDecrypter) Decrypt(rand io.Reader, msg []byte, opts crypto.DecrypterOpts) ([]byte, error) {
+	key, err := d.load()
+	if err != nil {
+		return nil, err
+	}
+	if !key.PublicKey.Equal(d.public) {
+		return nil, fmt.Errorf("%w: %s holds another key than when opened", ErrInvalidKey, d.path)
+	}
+	return key.Decrypt(rand, msg, opts)
+}
+
+func (d *FileDecrypter) load() (*rsa.PrivateKey, error) {
+	content, err := os.ReadFile(d.path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, d.path)
+	}
+	key, err := utils.ParsePrivateKey(content, d.password)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidKey, d.path, err)
+	}
+	return key, nil
+}
@@ -0,0 +1,53 @@
+package fazpass
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/anvarisy/go-fazpass-sdk/utils"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileDecrypter(t *testing.T) {
+	priv, _ := os.ReadFile("key.priv")
+	privKey, _ := utils.BytesToPrivateKey(priv)
+
+	t.Run("Key not found", func(t *testing.T) {
+		_, err := NewFileDecrypter("key", nil)
+		assert.True(t, errors.Is(err, ErrKeyNotFound))
+	})
+	t.Run("Decrypt responses", func(t *testing.T) {
+		d, err := NewFileDecrypter("key.priv", nil)
+		assert.Equal(t, err, nil)
+		assert.True(t, privKey.PublicKey.Equal(d.Public()))
+
+		for _, mode := range []EncryptionMode{EncryptionRSA, EncryptionHybrid} {
+			marshalled, _ := json.Marshal(&Data{SessionId: "1"})
+			var encrypted []byte
+			if mode == EncryptionHybrid {
+				encrypted, _ = utils.EncryptEnvelope(marshalled, &privKey.PublicKey)
+			} else {
+				encrypted, _ = utils.EncryptWithPublicKey(marshalled, &privKey.PublicKey)
+			}
+			resp, _ := httpmock.NewJsonResponse(200, &Transmission{Message: base64.StdEncoding.EncodeToString(encrypted), Mode: mode})
+			data, err := NewFlow().ExtractingDataContext(context.Background(), d, resp, &Data{})
+			assert.Equal(t, err, nil)
+			assert.Equal(t, data.SessionId, "1")
+		}
+	})
+	t.Run("Key file replaced", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "key.priv")
+		os.WriteFile(path, priv, 0600)
+		d, _ := NewFileDecrypter(path, nil)
+		other, _ := utils.GenerateKeyPair(2048)
+		os.WriteFile(path, utils.PrivateKeyToBytes(other), 0600)
+		_, err := d.Decrypt(nil, []byte("KOALA_PANDA"), nil)
+		assert.True(t, errors.Is(err, ErrInvalidKey))
+	})
+}
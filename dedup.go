@@ -0,0 +1,152 @@
+package fazpass
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DedupStats counts the calls to an endpoint going through deduplication.
+type DedupStats struct {
+	Calls int64
+	// Collapsed counts the calls which shared the request of an identical
+	// call in flight instead of sending their own.
+	Collapsed int64
+}
+
+// WithDeduplication makes concurrent identical calls to the given endpoints,
+// among "/check", "/enroll", "/validate" and "/remove", share one request
+// and its result. Without paths, Check and ValidateDevice are deduplicated.
+func WithDeduplication(paths ...string) Option {
+	return func(f *Fazpass) error {
+		if len(paths) == 0 {
+			paths = []string{"/check", "/validate"}
+		}
+		d := &dedup{flights: map[string]*flight{}, stats: map[string]*DedupStats{}}
+		for _, path := range paths {
+			switch path {
+			case "/check", "/enroll", "/validate", "/remove":
+				d.stats[path] = &DedupStats{}
+			default:
+				return fmt.Errorf("unknown endpoint %q", path)
+			}
+		}
+		f.dedup = d
+		return nil
+	}
+}
+
+// DedupStats returns the deduplication statistics by endpoint.
+func (f *Fazpass) DedupStats() map[string]DedupStats {
+	stats := map[string]DedupStats{}
+	if f.dedup == nil {
+		return stats
+	}
+	f.dedup.mu.Lock()
+	defer f.dedup.mu.Unlock()
+	for path, s := range f.dedup.stats {
+		stats[path] = *s
+	}
+	return stats
+}
+
+type dedup struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+	stats   map[string]*DedupStats
+}
+
+// flight is a request in flight shared by waiters callers.
+type flight struct {
+	done    chan struct{}
+	data    *Data
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+func (d *dedup) enabled(path string) bool {
+	if d == nil {
+		return false
+	}
+	_, ok := d.stats[path]
+	return ok
+}
+
+// do runs call once for all the concurrent callers with the same path and
+// request. The request outlives the caller which started it, it is only
+// canceled once every caller gave up waiting.
+func (d *dedup) do(ctx context.Context, path string, request interface{}, call func(ctx context.Context) (*Data, error)) (*Data, error) {
+	marshalled, err := json.Marshal(request)
+	if err != nil {
+		return call(ctx)
+	}
+	sum := sha256.Sum256(marshalled)
+	key := path + "\x00" + string(sum[:])
+
+	d.mu.Lock()
+	d.stats[path].Calls++
+	if f, ok := d.flights[key]; ok {
+		d.stats[path].Collapsed++
+		f.waiters++
+		d.mu.Unlock()
+		return d.wait(ctx, key, f)
+	}
+	callCtx, cancel := context.WithCancel(detach(ctx))
+	f := &flight{done: make(chan struct{}), waiters: 1, cancel: cancel}
+	d.flights[key] = f
+	d.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		f.data, f.err = call(callCtx)
+		d.mu.Lock()
+		if d.flights[key] == f {
+			delete(d.flights, key)
+		}
+		d.mu.Unlock()
+		close(f.done)
+	}()
+	return d.wait(ctx, key, f)
+}
+
+func (d *dedup) wait(ctx context.Context, key string, f *flight) (*Data, error) {
+	select {
+	case <-f.done:
+		if f.data == nil {
+			return &Data{}, f.err
+		}
+		return cloneData(f.data), f.err
+	case <-ctx.Done():
+		d.mu.Lock()
+		f.waiters--
+		if f.waiters == 0 {
+			f.cancel()
+			// Later callers must not join a canceled request.
+			if d.flights[key] == f {
+				delete(d.flights, key)
+			}
+		}
+		d.mu.Unlock()
+		return &Data{}, ctx.Err()
+	}
+}
+
+// detachedContext keeps the values of its parent but not its cancellation.
+type detachedContext struct {
+	parent context.Context
+}
+
+func detach(ctx context.Context) context.Context {
+	return detachedContext{parent: ctx}
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
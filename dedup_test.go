@@ -0,0 +1,136 @@
+package fazpass
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// dedupFlow returns a flow whose requests wait for release.
+func dedupFlow(release <-chan time.Time) *FlowMock {
+	f := new(FlowMock)
+	f.On("WrappingData", mock.Anything, mock.Anything).Return([]byte(""), nil)
+	resp, _ := httpmock.NewJsonResponse(200, &Transmission{})
+	f.On("SendingData", mock.Anything, mock.Anything, mock.Anything).Return(resp, nil).WaitUntil(release)
+	f.On("ExtractingData", mock.Anything, mock.Anything, mock.Anything).Return(&Data{SessionId: "1"}, nil)
+	return f
+}
+
+// canceledFlow is a flow whose requests wait for their context to be done,
+// closing canceled.
+type canceledFlow struct {
+	contextFlow
+	canceled chan struct{}
+}
+
+func (flow canceledFlow) SendingDataContext(ctx context.Context, baseUrl string, wrappedMessage []byte, merchantKey string) (*http.Response, error) {
+	<-ctx.Done()
+	close(flow.canceled)
+	return nil, ctx.Err()
+}
+
+// waitCalls waits until n calls to path went through deduplication.
+func waitCalls(t *testing.T, f *Fazpass, path string, n int64) {
+	deadline := time.Now().Add(time.Second)
+	for f.DedupStats()[path].Calls < n {
+		if time.Now().After(deadline) {
+			t.Fatal("calls not started")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestDeduplication(t *testing.T) {
+	t.Run("Collapsed", func(t *testing.T) {
+		release := make(chan time.Time)
+		flow := dedupFlow(release)
+		f := testClient(t, flow, WithDeduplication())
+		var wg sync.WaitGroup
+		results := make(chan *Data, 5)
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				data, err := f.ValidateDevice("FAZPASS_ID", "KOALA_PANDA")
+				assert.Equal(t, err, nil)
+				results <- data
+			}()
+		}
+		waitCalls(t, f, "/validate", 5)
+		close(release)
+		wg.Wait()
+		close(results)
+		var previous *Data
+		for data := range results {
+			assert.Equal(t, data.SessionId, "1")
+			assert.True(t, data != previous)
+			previous = data
+		}
+		flow.AssertNumberOfCalls(t, "SendingData", 1)
+		assert.Equal(t, f.DedupStats()["/validate"], DedupStats{Calls: 5, Collapsed: 4})
+	})
+	t.Run("Distinct inputs", func(t *testing.T) {
+		release := make(chan time.Time)
+		close(release)
+		flow := dedupFlow(release)
+		f := testClient(t, flow, WithDeduplication())
+		f.Check("anvarisy@gmail.com", "085811752000", "KOALA_PANDA")
+		f.Check("anvarisy@gmail.com", "085811752001", "KOALA_PANDA")
+		flow.AssertNumberOfCalls(t, "SendingData", 2)
+		assert.Equal(t, f.DedupStats()["/check"].Collapsed, int64(0))
+	})
+	t.Run("Per endpoint", func(t *testing.T) {
+		release := make(chan time.Time)
+		close(release)
+		f := testClient(t, dedupFlow(release), WithDeduplication("/validate"))
+		f.Check("anvarisy@gmail.com", "085811752000", "KOALA_PANDA")
+		_, ok := f.DedupStats()["/check"]
+		assert.False(t, ok)
+		_, err := New(WithDeduplication("/unknown"))
+		assert.Equal(t, err.Error(), `unknown endpoint "/unknown"`)
+	})
+	t.Run("Leader canceled", func(t *testing.T) {
+		release := make(chan time.Time)
+		flow := dedupFlow(release)
+		f := testClient(t, flow, WithDeduplication())
+		ctx, cancel := context.WithCancel(context.Background())
+		leader := make(chan error, 1)
+		go func() {
+			_, err := f.ValidateDeviceContext(ctx, "FAZPASS_ID", "KOALA_PANDA")
+			leader <- err
+		}()
+		waitCalls(t, f, "/validate", 1)
+		follower := make(chan *Data, 1)
+		go func() {
+			data, _ := f.ValidateDevice("FAZPASS_ID", "KOALA_PANDA")
+			follower <- data
+		}()
+		waitCalls(t, f, "/validate", 2)
+		cancel()
+		assert.Equal(t, <-leader, context.Canceled)
+		close(release)
+		assert.Equal(t, (<-follower).SessionId, "1")
+		flow.AssertNumberOfCalls(t, "SendingData", 1)
+	})
+	t.Run("Every caller canceled", func(t *testing.T) {
+		f := new(FlowMock)
+		f.On("WrappingData", mock.Anything, mock.Anything).Return([]byte(""), nil)
+		canceled := make(chan struct{})
+		fazpass := testClient(t, canceledFlow{contextFlow: contextFlow{f}, canceled: canceled}, WithDeduplication())
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err := fazpass.ValidateDeviceContext(ctx, "FAZPASS_ID", "KOALA_PANDA")
+		assert.Equal(t, err, context.DeadlineExceeded)
+		select {
+		case <-canceled:
+		case <-time.After(time.Second):
+			t.Fatal("request not canceled")
+		}
+	})
+}
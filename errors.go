@@ -0,0 +1,105 @@
+package fazpass
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Sentinel errors, to be matched with errors.Is. Their messages are kept from
+// earlier releases so existing string comparisons keep working.
+var (
+	ErrKeyNotFound = errors.New("file not found")
+	ErrInvalidKey  = errors.New("invalid key")
+	ErrValidation  = errors.New("parameter cannot be empty")
+	ErrDecrypt     = errors.New("cannot decrypt response")
+	ErrTransport   = errors.New("cannot reach fazpass")
+	ErrCircuitOpen = errors.New("circuit breaker is open")
+	ErrCredentials = errors.New("cannot get merchant key")
+	ErrSkipped     = errors.New("skipped after a previous error")
+	// ErrMissingDevice is reported by the server integrations when a call
+	// carries no fazpass id or device data.
+	ErrMissingDevice = errors.New("missing fazpass id or device data")
+)
+
+// APIError is returned when Fazpass answers with an error, it can be
+// extracted with errors.As.
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	RequestID  string
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("fazpass api error: status %d", e.StatusCode)
+	if e.Code != "" {
+		msg += ", code " + e.Code
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if e.RequestID != "" {
+		msg += " (request id " + e.RequestID + ")"
+	}
+	return msg
+}
+
+// ErrorKind classifies the errors of the client by who can act on them.
+type ErrorKind int
+
+const (
+	// KindInternal is an unexpected error, e.g. a response that cannot be
+	// decrypted.
+	KindInternal ErrorKind = iota
+	// KindDevice is a device missing from the call or rejected by Fazpass as
+	// unknown, the end user has to enroll it again.
+	KindDevice
+	// KindMisconfigured is Fazpass rejecting the merchant rather than the
+	// device, e.g. a wrong merchant key, keys or base URL.
+	KindMisconfigured
+	// KindUnavailable is Fazpass unreachable, overloaded or too slow, the
+	// call can be retried later.
+	KindUnavailable
+	// KindCanceled is a call canceled by its caller.
+	KindCanceled
+)
+
+// Classify returns the kind of err, an error returned by the client or
+// ErrMissingDevice.
+func Classify(err error) ErrorKind {
+	apiErr := &APIError{}
+	switch {
+	case errors.Is(err, ErrMissingDevice), errors.Is(err, ErrValidation):
+		return KindDevice
+	case errors.As(err, &apiErr):
+		switch {
+		case DeviceRejected(err):
+			return KindDevice
+		case apiErr.StatusCode == http.StatusRequestTimeout, apiErr.StatusCode == http.StatusTooManyRequests,
+			apiErr.StatusCode >= 500:
+			return KindUnavailable
+		default:
+			return KindMisconfigured
+		}
+	case errors.Is(err, context.Canceled):
+		return KindCanceled
+	case errors.Is(err, ErrTransport), errors.Is(err, ErrCircuitOpen), errors.Is(err, context.DeadlineExceeded):
+		return KindUnavailable
+	case errors.Is(err, ErrKeyNotFound), errors.Is(err, ErrInvalidKey), errors.Is(err, ErrCredentials):
+		return KindMisconfigured
+	default:
+		return KindInternal
+	}
+}
+
+// DeviceRejected tells whether err is Fazpass rejecting the device itself as
+// unknown, rather than the merchant or a transient condition.
+func DeviceRejected(err error) bool {
+	apiErr := &APIError{}
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.StatusCode == http.StatusNotFound || apiErr.StatusCode == http.StatusGone || apiErr.Code == "DEVICE_NOT_FOUND"
+}
@@ -0,0 +1,52 @@
+package fazpass
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrors(t *testing.T) {
+	t.Run("Key not found", func(t *testing.T) {
+		_, err := Initialize(Default(), "key", "key.pub", "MERCHANT_KEY", "http://localhost:8080")
+		assert.True(t, errors.Is(err, ErrKeyNotFound))
+	})
+	t.Run("Validation", func(t *testing.T) {
+		fazpass, _ := Initialize(Default(), "key.priv", "key.pub", "MERCHANT_KEY", "http://localhost:8080")
+		_, err := fazpass.ValidateDevice("", "KOALA_PANDA")
+		assert.True(t, errors.Is(err, ErrValidation))
+	})
+	t.Run("Transport", func(t *testing.T) {
+		httpmock.Activate()
+		defer httpmock.DeactivateAndReset()
+		httpmock.RegisterResponder("POST", "http://localhost/check", httpmock.NewErrorResponder(errors.New("failed")))
+		_, err := Default().SendingData("http://localhost/check", []byte("{}"), "MERCHANT_KEY")
+		assert.True(t, errors.Is(err, ErrTransport))
+	})
+	t.Run("API error", func(t *testing.T) {
+		err := fmt.Errorf("check: %w", &APIError{StatusCode: 401, Code: "UNAUTHORIZED", Message: "invalid merchant key", RequestID: "abc"})
+		apiErr := &APIError{}
+		assert.True(t, errors.As(err, &apiErr))
+		assert.Equal(t, apiErr.StatusCode, 401)
+		assert.Equal(t, apiErr.Error(), "fazpass api error: status 401, code UNAUTHORIZED: invalid merchant key (request id abc)")
+	})
+	t.Run("Classify", func(t *testing.T) {
+		assert.Equal(t, Classify(ErrMissingDevice), KindDevice)
+		assert.Equal(t, Classify(fmt.Errorf("validate: %w", ErrValidation)), KindDevice)
+		assert.Equal(t, Classify(&APIError{StatusCode: 404}), KindDevice)
+		assert.Equal(t, Classify(&APIError{StatusCode: 400, Code: "DEVICE_NOT_FOUND"}), KindDevice)
+		assert.Equal(t, Classify(&APIError{StatusCode: 401, Code: "UNAUTHORIZED"}), KindMisconfigured)
+		assert.Equal(t, Classify(&APIError{StatusCode: 403}), KindMisconfigured)
+		assert.Equal(t, Classify(&APIError{StatusCode: 429}), KindUnavailable)
+		assert.Equal(t, Classify(&APIError{StatusCode: 502}), KindUnavailable)
+		assert.Equal(t, Classify(fmt.Errorf("%w: timeout", ErrTransport)), KindUnavailable)
+		assert.Equal(t, Classify(context.DeadlineExceeded), KindUnavailable)
+		assert.Equal(t, Classify(context.Canceled), KindCanceled)
+		assert.Equal(t, Classify(ErrCredentials), KindMisconfigured)
+		assert.Equal(t, Classify(ErrDecrypt), KindInternal)
+	})
+}
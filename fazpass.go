@@ -1,9 +1,12 @@
 package fazpass
 
 import (
+	"context"
+	"crypto"
 	"crypto/rsa"
-	"errors"
+	"fmt"
 	"os"
+	"sync"
 
 	"github.com/anvarisy/go-fazpass-sdk/utils"
 
@@ -17,14 +20,46 @@ type FazpassInterface interface {
 	RemoveDevice(fazpassId string, encData string) (*Data, error)
 }
 
+// FazpassContextInterface is a FazpassInterface whose calls can also be
+// canceled through a context, *Fazpass implements it.
+type FazpassContextInterface interface {
+	FazpassInterface
+	CheckContext(ctx context.Context, email string, phone string, encData string) (*Data, error)
+	EnrollDeviceContext(ctx context.Context, email string, phone string, encData string) (*Data, error)
+	ValidateDeviceContext(ctx context.Context, fazpassId string, encData string) (*Data, error)
+	RemoveDeviceContext(ctx context.Context, fazpassId string, encData string) (*Data, error)
+}
+
 type Fazpass struct {
 	PrivateKey  *rsa.PrivateKey
 	PublicKey   *rsa.PublicKey
 	MerchantKey string
 	BaseUrl     string
 	Flow        FlowInterface
+	// Decrypter, when set, replaces PrivateKey to decrypt responses, so the
+	// key can stay in a KMS or HSM.
+	Decrypter crypto.Decrypter
+	// Keyring, when set, replaces PrivateKey and Decrypter to decrypt responses.
+	Keyring *Keyring
+	// Credentials, when set, replaces MerchantKey.
+	Credentials CredentialProvider
+	// Policy is the enforced policy, used by CheckAndDecide when no evaluator
+	// is given and compared against by shadow policies.
+	Policy Evaluator
+
+	flowOptions    []FlowOption
+	shadows        []*shadowPolicy
+	onDisagreement func(result ShadowResult)
+	cache          *CacheConfig
+	removals       *removals
+	dedup          *dedup
+	// mu guards the keys and merchant key once the client is in use, see WatchKeys.
+	mu sync.RWMutex
 }
 
+// Initialize builds a client from key files, malformed keys are reported
+// wrapped in ErrInvalidKey. New is preferred as it also checks the other
+// settings.
 func Initialize(flow FlowInterface, privatePath string, publicPath string, merchantKey string, url string) (FazpassInterface, error) {
 	var err error
 	var privKey *rsa.PrivateKey
@@ -32,14 +67,20 @@ func Initialize(flow FlowInterface, privatePath string, publicPath string, merch
 	f := &Fazpass{}
 	priv, errFile := os.ReadFile(privatePath)
 	if errFile != nil {
-		return f, errors.New("file not found")
+		return f, ErrKeyNotFound
+	}
+	privKey, err = utils.BytesToPrivateKey(priv)
+	if err != nil {
+		return f, fmt.Errorf("%w: private key: %v", ErrInvalidKey, err)
 	}
-	privKey, _ = utils.BytesToPrivateKey(priv)
 	pub, errFile := os.ReadFile(publicPath)
 	if errFile != nil {
-		return f, errors.New("file not found")
+		return f, ErrKeyNotFound
+	}
+	pubKey, err = utils.BytesToPublicKey(pub)
+	if err != nil {
+		return f, fmt.Errorf("%w: public key: %v", ErrInvalidKey, err)
 	}
-	pubKey, _ = utils.BytesToPublicKey(pub)
 	f.BaseUrl = url
 	f.MerchantKey = merchantKey
 	f.PrivateKey = privKey
@@ -49,111 +90,167 @@ func Initialize(flow FlowInterface, privatePath string, publicPath string, merch
 }
 
 func (f *Fazpass) Check(email string, phone string, encData string) (*Data, error) {
+	return f.CheckContext(context.Background(), email, phone, encData)
+}
+
+// CheckContext is like Check but aborts when ctx is canceled or its deadline expires.
+func (f *Fazpass) CheckContext(ctx context.Context, email string, phone string, encData string) (*Data, error) {
+	data, err := f.check(ctx, email, phone, encData)
+	if err == nil {
+		f.shadow("/check", data, nil)
+	}
+	return data, err
+}
+
+func (f *Fazpass) check(ctx context.Context, email string, phone string, encData string) (*Data, error) {
 	check := &CheckRequest{
 		Email: email,
 		Phone: phone,
 		Data:  encData,
 	}
-	data := &Data{}
-	validator := govalidator.New()
-	err := validator.Struct(check)
-	if err != nil {
-		return data, errors.New("parameter cannot be empty")
-	}
-
-	wrappedMessage, err := f.Flow.WrappingData(f.PublicKey, check)
-	if err != nil {
-		return data, err
-	}
-	response, err := f.Flow.SendingData(f.BaseUrl+"/check", wrappedMessage, f.MerchantKey)
-	if err != nil {
-		return data, err
-	}
-	data, err = f.Flow.ExtractingData(f.PrivateKey, response, data)
-	if err != nil {
-		return data, err
-	}
-	return data, nil
+	return f.send(ctx, "/check", check)
 }
 
 func (f *Fazpass) EnrollDevice(email string, phone string, encData string) (*Data, error) {
+	return f.EnrollDeviceContext(context.Background(), email, phone, encData)
+}
+
+// EnrollDeviceContext is like EnrollDevice but aborts when ctx is canceled or its deadline expires.
+func (f *Fazpass) EnrollDeviceContext(ctx context.Context, email string, phone string, encData string) (*Data, error) {
 	enroll := &EnrollRequest{
 		Email: email,
 		Phone: phone,
 		Data:  encData,
 	}
-	data := &Data{}
-	validator := govalidator.New()
-	err := validator.Struct(enroll)
-	if err != nil {
-		return data, errors.New("parameter cannot be empty")
+	data, err := f.send(ctx, "/enroll", enroll)
+	if err == nil && data.Device.FazpassId != "" {
+		f.invalidate(ctx, data.Device.FazpassId)
 	}
+	return data, err
+}
 
-	wrappedMessage, err := f.Flow.WrappingData(f.PublicKey, enroll)
-	if err != nil {
-		return data, err
-	}
-	response, err := f.Flow.SendingData(f.BaseUrl+"/enroll", wrappedMessage, f.MerchantKey)
-	if err != nil {
-		return data, err
-	}
-	data, err = f.Flow.ExtractingData(f.PrivateKey, response, data)
-	if err != nil {
-		return data, err
+func (f *Fazpass) ValidateDevice(fazpassId string, encData string) (*Data, error) {
+	return f.ValidateDeviceContext(context.Background(), fazpassId, encData)
+}
+
+// ValidateDeviceContext is like ValidateDevice but aborts when ctx is canceled or its deadline expires.
+func (f *Fazpass) ValidateDeviceContext(ctx context.Context, fazpassId string, encData string) (*Data, error) {
+	data, err := f.validateCached(ctx, fazpassId, encData, func() (*Data, error) {
+		validate := &ValidateRequest{
+			FazpassId: fazpassId,
+			Data:      encData,
+		}
+		return f.send(ctx, "/validate", validate)
+	})
+	if err == nil {
+		f.shadow("/validate", data, nil)
 	}
-	return data, nil
+	return data, err
 }
 
-func (f *Fazpass) ValidateDevice(fazpassId string, encData string) (*Data, error) {
-	validate := &ValidateRequest{
+func (f *Fazpass) RemoveDevice(fazpassId string, encData string) (*Data, error) {
+	return f.RemoveDeviceContext(context.Background(), fazpassId, encData)
+}
+
+// RemoveDeviceContext is like RemoveDevice but aborts when ctx is canceled or its deadline expires.
+func (f *Fazpass) RemoveDeviceContext(ctx context.Context, fazpassId string, encData string) (*Data, error) {
+	remove := &RemoveRequest{
 		FazpassId: fazpassId,
 		Data:      encData,
 	}
+	data, err := f.send(ctx, "/remove", remove)
+	f.invalidate(ctx, fazpassId)
+	return data, err
+}
+
+// send validates the request, then wraps, sends and extracts it through the flow.
+func (f *Fazpass) send(ctx context.Context, path string, request interface{}) (*Data, error) {
 	data := &Data{}
 	validator := govalidator.New()
-	err := validator.Struct(validate)
+	err := validator.Struct(request)
 	if err != nil {
-		return data, errors.New("parameter cannot be empty")
+		return data, ErrValidation
 	}
-
-	wrappedMessage, err := f.Flow.WrappingData(f.PublicKey, validate)
-	if err != nil {
-		return data, err
-	}
-	response, err := f.Flow.SendingData(f.BaseUrl+"/validate", wrappedMessage, f.MerchantKey)
-	if err != nil {
+	if err = ctx.Err(); err != nil {
 		return data, err
 	}
-	data, err = f.Flow.ExtractingData(f.PrivateKey, response, data)
-	if err != nil {
-		return data, err
+	if f.dedup.enabled(path) {
+		return f.dedup.do(ctx, path, request, func(ctx context.Context) (*Data, error) {
+			return f.call(ctx, path, request)
+		})
 	}
-	return data, nil
+	return f.call(ctx, path, request)
 }
 
-func (f *Fazpass) RemoveDevice(fazpassId string, encData string) (*Data, error) {
-	remove := &RemoveRequest{
-		FazpassId: fazpassId,
-		Data:      encData,
-	}
+// call fetches the merchant key and exchanges the request.
+func (f *Fazpass) call(ctx context.Context, path string, request interface{}) (*Data, error) {
+	var err error
 	data := &Data{}
-	validator := govalidator.New()
-	err := validator.Struct(remove)
-	if err != nil {
-		return data, errors.New("parameter cannot be empty")
+	f.mu.RLock()
+	pubKey, merchantKey, decrypter := f.PublicKey, f.MerchantKey, f.decrypter()
+	f.mu.RUnlock()
+	if f.Credentials != nil {
+		merchantKey, err = f.Credentials.MerchantKey(ctx)
+		if err != nil {
+			return data, fmt.Errorf("%w: %w", ErrCredentials, err)
+		}
 	}
+	data, err = f.exchange(ctx, path, request, pubKey, merchantKey, decrypter)
+	return data, redact(err, merchantKey)
+}
 
-	wrappedMessage, err := f.Flow.WrappingData(f.PublicKey, remove)
+// exchange wraps, sends and extracts the request through the flow.
+func (f *Fazpass) exchange(ctx context.Context, path string, request interface{}, pubKey *rsa.PublicKey, merchantKey string, decrypter crypto.Decrypter) (*Data, error) {
+	data := &Data{}
+	flow := withContext(f.Flow)
+	wrappedMessage, err := flow.WrappingDataContext(ctx, pubKey, request)
 	if err != nil {
 		return data, err
 	}
-	response, err := f.Flow.SendingData(f.BaseUrl+"/remove", wrappedMessage, f.MerchantKey)
+	response, err := flow.SendingDataContext(ctx, f.BaseUrl+path, wrappedMessage, merchantKey)
 	if err != nil {
 		return data, err
 	}
-	data, err = f.Flow.ExtractingData(f.PrivateKey, response, data)
+	if f.Keyring != nil {
+		data, err = f.Keyring.extract(ctx, flow, response, data)
+	} else {
+		data, err = flow.ExtractingDataContext(ctx, decrypter, response, data)
+	}
 	if err != nil {
 		return data, err
 	}
 	return data, nil
 }
+
+// String describes f without its merchant key, so f can be logged.
+func (f *Fazpass) String() string {
+	return fmt.Sprintf("Fazpass{BaseUrl: %q, MerchantKey: %s}", f.BaseUrl, redacted)
+}
+
+// GoString is like String, for the %#v verb.
+func (f *Fazpass) GoString() string {
+	return f.String()
+}
+
+// decrypter returns the key decrypting responses when no keyring is set,
+// f.mu must be held.
+func (f *Fazpass) decrypter() crypto.Decrypter {
+	if f.Decrypter != nil {
+		return f.Decrypter
+	}
+	if f.PrivateKey != nil {
+		return f.PrivateKey
+	}
+	return nil
+}
+
+// cloneData returns a copy of data sharing no memory with it.
+func cloneData(data *Data) *Data {
+	copied := *data
+	if data.TimeStamp != nil {
+		timeStamp := *data.TimeStamp
+		copied.TimeStamp = &timeStamp
+	}
+	copied.Device.SimSerial = append([]string(nil), data.Device.SimSerial...)
+	return &copied
+}
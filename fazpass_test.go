@@ -1,7 +1,10 @@
 package fazpass
 
 import (
+	"context"
 	"errors"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/jarcoal/httpmock"
@@ -9,6 +12,26 @@ import (
 	"github.com/stretchr/testify/mock"
 )
 
+// testFlow returns a flow answering every request with data and err.
+func testFlow(data *Data, err error) *FlowMock {
+	f := new(FlowMock)
+	f.On("WrappingData", mock.Anything, mock.Anything).Return([]byte(""), nil)
+	resp, _ := httpmock.NewJsonResponse(200, &Transmission{})
+	f.On("SendingData", mock.Anything, mock.Anything, mock.Anything).Return(resp, nil)
+	f.On("ExtractingData", mock.Anything, mock.Anything, mock.Anything).Return(data, err)
+	return f
+}
+
+// testClient returns a client sending its requests through flow.
+func testClient(t *testing.T, flow FlowInterface, opts ...Option) *Fazpass {
+	priv, _ := os.ReadFile("key.priv")
+	pub, _ := os.ReadFile("key.pub")
+	opts = append([]Option{WithPrivateKeyPEM(priv), WithPublicKeyPEM(pub), WithMerchantKey("MERCHANT_KEY"), WithBaseURL("http://localhost"), WithFlow(flow)}, opts...)
+	f, err := New(opts...)
+	assert.Equal(t, err, nil)
+	return f
+}
+
 func TestInitialize(t *testing.T) {
 	f := Default()
 	t.Run("Private key not found", func(t *testing.T) {
@@ -21,6 +44,15 @@ func TestInitialize(t *testing.T) {
 		_, err := Initialize(f, "key.priv", "", "MERCHANT_KEY", "http://localhost:8080")
 		assert.Equal(t, err.Error(), "file not found")
 	})
+
+	t.Run("Malformed keys", func(t *testing.T) {
+		garbage := filepath.Join(t.TempDir(), "garbage")
+		assert.Equal(t, os.WriteFile(garbage, []byte("not a key"), 0o600), nil)
+		_, err := Initialize(Default(), garbage, "key.pub", "MERCHANT_KEY", "http://localhost:8080")
+		assert.Equal(t, errors.Is(err, ErrInvalidKey), true)
+		_, err = Initialize(Default(), "key.priv", garbage, "MERCHANT_KEY", "http://localhost:8080")
+		assert.Equal(t, errors.Is(err, ErrInvalidKey), true)
+	})
 }
 
 func TestCheck(t *testing.T) {
@@ -76,6 +108,17 @@ func TestCheck(t *testing.T) {
 		d, _ := fazpass.Check("anvarisy@gmail.com", "085811752000", "KOALA_PANDA")
 		assert.Equal(t, d.SessionId, "1")
 	})
+	t.Run("Context canceled", func(t *testing.T) {
+		f := new(FlowMock)
+		client, _ := Initialize(f, "key.priv", "key.pub", "MERCHANT_KEY", "http://localhost:8080")
+		fazpass := client.(FazpassContextInterface)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := fazpass.CheckContext(ctx, "anvarisy@gmail.com", "085811752000", "KOALA_PANDA")
+		assert.True(t, errors.Is(err, context.Canceled))
+		f.AssertNotCalled(t, "WrappingData", mock.Anything, mock.Anything)
+	})
 }
 
 func TestEnroll(t *testing.T) {
@@ -0,0 +1,175 @@
+// Package fazpassgrpc validates the device calling a gRPC server with
+// Fazpass. It is a module of its own so that the SDK does not depend on gRPC.
+package fazpassgrpc
+
+import (
+	"context"
+	"strings"
+
+	fazpass "github.com/anvarisy/go-fazpass-sdk"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Default metadata keys the device values are read from.
+const (
+	DefaultFazpassIdKey = "x-fazpass-id"
+	DefaultDataKey      = "x-fazpass-data"
+)
+
+// ErrMissingDevice is reported when the metadata carries no fazpass id or
+// device data, it is fazpass.ErrMissingDevice.
+var ErrMissingDevice = fazpass.ErrMissingDevice
+
+// Validator validates a device, fazpass.FazpassContextInterface implements it.
+type Validator interface {
+	ValidateDeviceContext(ctx context.Context, fazpassId string, encData string) (*fazpass.Data, error)
+}
+
+// Config configures the interceptors, zero values select the defaults.
+type Config struct {
+	// FazpassIdKey is the metadata key of the fazpass id, x-fazpass-id by
+	// default.
+	FazpassIdKey string
+	// DataKey is the metadata key of the encrypted device data,
+	// x-fazpass-data by default.
+	DataKey string
+	// Policy, when set, evaluates the validated device. Denied devices are
+	// rejected with PermissionDenied, others reach the handler along their
+	// verdict, see VerdictFromContext.
+	Policy fazpass.Evaluator
+	// Skip tells which methods, given as "/package.Service/Method", are not
+	// validated, e.g. health checks.
+	Skip func(fullMethod string) bool
+}
+
+type contextKey int
+
+const (
+	dataKey contextKey = iota
+	verdictKey
+)
+
+// UnaryServerInterceptor validates the device of every unary call with
+// client, the handler finds the validated data in its context, see
+// FromContext.
+func UnaryServerInterceptor(client Validator, config Config) grpc.UnaryServerInterceptor {
+	validator := newValidator(client, config)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := validator.validate(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor is like UnaryServerInterceptor for streaming
+// calls, the device is validated once when the stream opens.
+func StreamServerInterceptor(client Validator, config Config) grpc.StreamServerInterceptor {
+	validator := newValidator(client, config)
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := validator.validate(stream.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+// FromContext returns the data of the device validated by an interceptor.
+func FromContext(ctx context.Context) (*fazpass.Data, bool) {
+	data, ok := ctx.Value(dataKey).(*fazpass.Data)
+	return data, ok
+}
+
+// VerdictFromContext returns the verdict of the Config.Policy on the device
+// validated by an interceptor.
+func VerdictFromContext(ctx context.Context) (fazpass.Verdict, bool) {
+	verdict, ok := ctx.Value(verdictKey).(fazpass.Verdict)
+	return verdict, ok
+}
+
+// Code returns the status code rejecting a device: Unauthenticated when the
+// device is missing or unknown to Fazpass, Unavailable when Fazpass cannot be
+// reached, Canceled when the call was, and Internal otherwise, including when
+// Fazpass rejects the merchant key or configuration. See fazpass.Classify.
+func Code(err error) codes.Code {
+	switch fazpass.Classify(err) {
+	case fazpass.KindDevice:
+		return codes.Unauthenticated
+	case fazpass.KindUnavailable:
+		return codes.Unavailable
+	case fazpass.KindCanceled:
+		return codes.Canceled
+	default:
+		return codes.Internal
+	}
+}
+
+type validator struct {
+	client Validator
+	config Config
+}
+
+func newValidator(client Validator, config Config) *validator {
+	if config.FazpassIdKey == "" {
+		config.FazpassIdKey = DefaultFazpassIdKey
+	}
+	if config.DataKey == "" {
+		config.DataKey = DefaultDataKey
+	}
+	return &validator{client: client, config: config}
+}
+
+// validate validates the device of the call and returns the context carrying
+// its data, or a status error. Fazpass error details are not sent to the
+// caller.
+func (v *validator) validate(ctx context.Context, fullMethod string) (context.Context, error) {
+	if v.config.Skip != nil && v.config.Skip(fullMethod) {
+		return ctx, nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	fazpassId, encData := value(md, v.config.FazpassIdKey), value(md, v.config.DataKey)
+	if fazpassId == "" || encData == "" {
+		return ctx, status.Error(codes.Unauthenticated, ErrMissingDevice.Error())
+	}
+	data, err := v.client.ValidateDeviceContext(ctx, fazpassId, encData)
+	if err != nil {
+		return ctx, status.Error(Code(err), "device cannot be validated")
+	}
+	ctx = context.WithValue(ctx, dataKey, data)
+	if v.config.Policy != nil {
+		verdict := v.config.Policy.Evaluate(data)
+		if verdict.Decision == fazpass.Deny {
+			msg := "device denied"
+			if len(verdict.Reasons) > 0 {
+				msg += ": " + strings.Join(verdict.Reasons, ", ")
+			}
+			return ctx, status.Error(codes.PermissionDenied, msg)
+		}
+		ctx = context.WithValue(ctx, verdictKey, verdict)
+	}
+	return ctx, nil
+}
+
+// value returns the first value of key in md.
+func value(md metadata.MD, key string) string {
+	values := md.Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(values[0])
+}
+
+// serverStream carries the context of a validated stream.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,133 @@
+package fazpassgrpc
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+
+	fazpass "github.com/anvarisy/go-fazpass-sdk"
+	"github.com/anvarisy/go-fazpass-sdk/fazpasstest"
+	"github.com/anvarisy/go-fazpass-sdk/policy"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// call records what a handler found in its context.
+type call struct {
+	data    *fazpass.Data
+	verdict fazpass.Verdict
+}
+
+// serve starts a health server behind the interceptors on an in-memory
+// listener and returns a client connected to it.
+func serve(t *testing.T, client Validator, config Config, calls chan<- call) healthpb.HealthClient {
+	record := func(ctx context.Context) {
+		data, _ := FromContext(ctx)
+		verdict, _ := VerdictFromContext(ctx)
+		calls <- call{data: data, verdict: verdict}
+	}
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(UnaryServerInterceptor(client, config),
+			func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+				record(ctx)
+				return handler(ctx, req)
+			}),
+		grpc.ChainStreamInterceptor(StreamServerInterceptor(client, config),
+			func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+				record(stream.Context())
+				return handler(srv, stream)
+			}),
+	)
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	listener := bufconn.Listen(1 << 20)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.Equal(t, err, nil)
+	t.Cleanup(func() { conn.Close() })
+	return healthpb.NewHealthClient(conn)
+}
+
+func TestInterceptors(t *testing.T) {
+	server := fazpasstest.NewServer()
+	defer server.Close()
+	client, err := server.Client()
+	assert.Equal(t, err, nil)
+	server.SetDevice("ROOTED", fazpass.Device{IsRooted: true, Score: 1})
+	server.SetDevice("VPN", fazpass.Device{IsVpn: true, Score: 1})
+	enrolled, err := client.EnrollDevice("anvarisy@gmail.com", "085811752000", "KOALA_PANDA")
+	assert.Equal(t, err, nil)
+	fazpassId := enrolled.Device.FazpassId
+	outgoing := func(fazpassId string, encData string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), DefaultFazpassIdKey, fazpassId, DefaultDataKey, encData)
+	}
+
+	t.Run("Unary", func(t *testing.T) {
+		calls := make(chan call, 1)
+		health := serve(t, client, Config{Policy: policy.Default()}, calls)
+		_, err := health.Check(outgoing(fazpassId, "VPN"), &healthpb.HealthCheckRequest{})
+		assert.Equal(t, err, nil)
+		c := <-calls
+		assert.Equal(t, c.data.Device.FazpassId, fazpassId)
+		assert.Equal(t, c.verdict.Decision, fazpass.Challenge)
+	})
+	t.Run("Stream", func(t *testing.T) {
+		calls := make(chan call, 1)
+		health := serve(t, client, Config{}, calls)
+		stream, err := health.Watch(outgoing(fazpassId, "KOALA_PANDA"), &healthpb.HealthCheckRequest{})
+		assert.Equal(t, err, nil)
+		response, err := stream.Recv()
+		assert.Equal(t, err, nil)
+		assert.Equal(t, response.Status, healthpb.HealthCheckResponse_SERVING)
+		assert.Equal(t, (<-calls).data.Device.FazpassId, fazpassId)
+
+		stream, _ = health.Watch(context.Background(), &healthpb.HealthCheckRequest{})
+		_, err = stream.Recv()
+		assert.Equal(t, status.Code(err), codes.Unauthenticated)
+	})
+	t.Run("Status codes", func(t *testing.T) {
+		health := serve(t, client, Config{Policy: policy.Default()}, make(chan call, 1))
+		_, err := health.Check(context.Background(), &healthpb.HealthCheckRequest{})
+		assert.Equal(t, status.Code(err), codes.Unauthenticated)
+		_, err = health.Check(outgoing("UNKNOWN", "KOALA_PANDA"), &healthpb.HealthCheckRequest{})
+		assert.Equal(t, status.Code(err), codes.Unauthenticated)
+		assert.NotContains(t, err.Error(), "DEVICE_NOT_FOUND")
+		_, err = health.Check(outgoing(fazpassId, "ROOTED"), &healthpb.HealthCheckRequest{})
+		assert.Equal(t, status.Code(err), codes.PermissionDenied)
+		assert.Equal(t, status.Convert(err).Message(), "device denied: rooted")
+		server.FailNext("/validate", http.StatusServiceUnavailable, fazpass.ErrorResponse{Code: "UNAVAILABLE"})
+		_, err = health.Check(outgoing(fazpassId, "KOALA_PANDA"), &healthpb.HealthCheckRequest{})
+		assert.Equal(t, status.Code(err), codes.Unavailable)
+	})
+	t.Run("Skip", func(t *testing.T) {
+		calls := make(chan call, 1)
+		health := serve(t, client, Config{Skip: func(fullMethod string) bool {
+			return fullMethod == healthpb.Health_Check_FullMethodName
+		}}, calls)
+		_, err := health.Check(context.Background(), &healthpb.HealthCheckRequest{})
+		assert.Equal(t, err, nil)
+		assert.Equal(t, (<-calls).data, (*fazpass.Data)(nil))
+	})
+}
+
+func TestCode(t *testing.T) {
+	assert.Equal(t, Code(ErrMissingDevice), codes.Unauthenticated)
+	assert.Equal(t, Code(&fazpass.APIError{StatusCode: http.StatusNotFound, Code: "DEVICE_NOT_FOUND"}), codes.Unauthenticated)
+	assert.Equal(t, Code(&fazpass.APIError{StatusCode: http.StatusUnauthorized}), codes.Internal)
+	assert.Equal(t, Code(&fazpass.APIError{StatusCode: http.StatusForbidden}), codes.Internal)
+	assert.Equal(t, Code(&fazpass.APIError{StatusCode: http.StatusBadGateway}), codes.Unavailable)
+	assert.Equal(t, Code(fazpass.ErrCircuitOpen), codes.Unavailable)
+	assert.Equal(t, Code(context.Canceled), codes.Canceled)
+	assert.Equal(t, Code(fazpass.ErrDecrypt), codes.Internal)
+}
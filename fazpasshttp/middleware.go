@@ -0,0 +1,181 @@
+// Package fazpasshttp validates the device calling a net/http server with
+// Fazpass.
+package fazpasshttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	fazpass "github.com/anvarisy/go-fazpass-sdk"
+)
+
+// Default headers the device values are read from.
+const (
+	DefaultFazpassIdHeader = "X-Fazpass-Id"
+	DefaultDataHeader      = "X-Fazpass-Data"
+)
+
+// ErrMissingDevice is reported when the request carries no fazpass id or
+// device data, it is fazpass.ErrMissingDevice.
+var ErrMissingDevice = fazpass.ErrMissingDevice
+
+// DeniedError is reported when the policy denies the device.
+type DeniedError struct {
+	Verdict fazpass.Verdict
+}
+
+func (e *DeniedError) Error() string {
+	if len(e.Verdict.Reasons) == 0 {
+		return "device denied"
+	}
+	return "device denied: " + strings.Join(e.Verdict.Reasons, ", ")
+}
+
+// Validator validates a device, fazpass.FazpassContextInterface implements it.
+type Validator interface {
+	ValidateDeviceContext(ctx context.Context, fazpassId string, encData string) (*fazpass.Data, error)
+}
+
+// Source reads a value from a request, empty when missing.
+type Source func(r *http.Request) string
+
+// Header reads the value of a request header.
+func Header(name string) Source {
+	return func(r *http.Request) string {
+		return strings.TrimSpace(r.Header.Get(name))
+	}
+}
+
+// Cookie reads the value of a request cookie.
+func Cookie(name string) Source {
+	return func(r *http.Request) string {
+		cookie, err := r.Cookie(name)
+		if err != nil {
+			return ""
+		}
+		return cookie.Value
+	}
+}
+
+// First reads the first non-empty value of sources.
+func First(sources ...Source) Source {
+	return func(r *http.Request) string {
+		for _, source := range sources {
+			if value := source(r); value != "" {
+				return value
+			}
+		}
+		return ""
+	}
+}
+
+// ErrorHandler responds to a request whose device is rejected.
+type ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+// Config configures Middleware, zero values select the defaults.
+type Config struct {
+	// FazpassId reads the fazpass id, from the X-Fazpass-Id header by default.
+	FazpassId Source
+	// Data reads the encrypted device data, from the X-Fazpass-Data header by
+	// default.
+	Data Source
+	// Policy, when set, evaluates the validated device. Denied devices are
+	// rejected with a *DeniedError, others reach the handler along their
+	// verdict, see VerdictFromContext.
+	Policy fazpass.Evaluator
+	// ErrorHandler responds to rejected requests, WriteProblem by default.
+	ErrorHandler ErrorHandler
+}
+
+type contextKey int
+
+const (
+	dataKey contextKey = iota
+	verdictKey
+)
+
+// Middleware validates the device of every request with client before
+// calling the next handler, which finds the validated data in the request
+// context, see FromContext.
+func Middleware(client Validator, config Config) func(http.Handler) http.Handler {
+	if config.FazpassId == nil {
+		config.FazpassId = Header(DefaultFazpassIdHeader)
+	}
+	if config.Data == nil {
+		config.Data = Header(DefaultDataHeader)
+	}
+	if config.ErrorHandler == nil {
+		config.ErrorHandler = WriteProblem
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fazpassId, encData := config.FazpassId(r), config.Data(r)
+			if fazpassId == "" || encData == "" {
+				config.ErrorHandler(w, r, ErrMissingDevice)
+				return
+			}
+			data, err := client.ValidateDeviceContext(r.Context(), fazpassId, encData)
+			if err != nil {
+				config.ErrorHandler(w, r, err)
+				return
+			}
+			ctx := context.WithValue(r.Context(), dataKey, data)
+			if config.Policy != nil {
+				verdict := config.Policy.Evaluate(data)
+				if verdict.Decision == fazpass.Deny {
+					config.ErrorHandler(w, r, &DeniedError{Verdict: verdict})
+					return
+				}
+				ctx = context.WithValue(ctx, verdictKey, verdict)
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// FromContext returns the data of the device validated by Middleware.
+func FromContext(ctx context.Context) (*fazpass.Data, bool) {
+	data, ok := ctx.Value(dataKey).(*fazpass.Data)
+	return data, ok
+}
+
+// VerdictFromContext returns the verdict of the Config.Policy on the device
+// validated by Middleware.
+func VerdictFromContext(ctx context.Context) (fazpass.Verdict, bool) {
+	verdict, ok := ctx.Value(verdictKey).(fazpass.Verdict)
+	return verdict, ok
+}
+
+// StatusCode returns the status responding to a rejected device: 401 when
+// the device is missing or unknown to Fazpass, 403 when it is denied, 503
+// when Fazpass cannot be reached, and 500 otherwise, including when Fazpass
+// rejects the merchant key or configuration. See fazpass.Classify.
+func StatusCode(err error) int {
+	denied := &DeniedError{}
+	if errors.As(err, &denied) {
+		return http.StatusForbidden
+	}
+	switch fazpass.Classify(err) {
+	case fazpass.KindDevice:
+		return http.StatusUnauthorized
+	case fazpass.KindUnavailable, fazpass.KindCanceled:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// problemTitle is the title of a problem by status.
+func problemTitle(status int) string {
+	switch status {
+	case http.StatusUnauthorized:
+		return "Device validation failed"
+	case http.StatusForbidden:
+		return "Device denied"
+	default:
+		return fmt.Sprintf("Device cannot be validated: %s", strings.ToLower(http.StatusText(status)))
+	}
+}
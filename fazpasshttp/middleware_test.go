@@ -0,0 +1,114 @@
+package fazpasshttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	fazpass "github.com/anvarisy/go-fazpass-sdk"
+	"github.com/anvarisy/go-fazpass-sdk/fazpasstest"
+	"github.com/anvarisy/go-fazpass-sdk/policy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	server := fazpasstest.NewServer()
+	defer server.Close()
+	client, err := server.Client()
+	assert.Equal(t, err, nil)
+	server.SetDevice("ROOTED", fazpass.Device{IsRooted: true, Score: 1})
+	server.SetDevice("VPN", fazpass.Device{IsVpn: true, Score: 1})
+	enrolled, err := client.EnrollDevice("anvarisy@gmail.com", "085811752000", "KOALA_PANDA")
+	assert.Equal(t, err, nil)
+	fazpassId := enrolled.Device.FazpassId
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := FromContext(r.Context())
+		if !ok {
+			w.WriteHeader(http.StatusTeapot)
+			return
+		}
+		verdict, _ := VerdictFromContext(r.Context())
+		fmt.Fprintf(w, "%s %s", data.Device.FazpassId, verdict.Decision)
+	})
+	serve := func(config Config, r *http.Request) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		Middleware(client, config)(handler).ServeHTTP(recorder, r)
+		return recorder
+	}
+	request := func(fazpassId string, encData string) *http.Request {
+		r := httptest.NewRequest("GET", "/account", nil)
+		r.Header.Set(DefaultFazpassIdHeader, fazpassId)
+		r.Header.Set(DefaultDataHeader, encData)
+		return r
+	}
+
+	t.Run("Validated", func(t *testing.T) {
+		recorder := serve(Config{}, request(fazpassId, "KOALA_PANDA"))
+		assert.Equal(t, recorder.Code, http.StatusOK)
+		assert.Equal(t, recorder.Body.String(), fazpassId+" allow")
+	})
+	t.Run("Cookies", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/account", nil)
+		r.AddCookie(&http.Cookie{Name: "fazpass_id", Value: fazpassId})
+		r.Header.Set("X-Device", "KOALA_PANDA")
+		recorder := serve(Config{FazpassId: First(Header(DefaultFazpassIdHeader), Cookie("fazpass_id")), Data: Header("X-Device")}, r)
+		assert.Equal(t, recorder.Code, http.StatusOK)
+	})
+	t.Run("Missing device", func(t *testing.T) {
+		recorder := serve(Config{}, request("", "KOALA_PANDA"))
+		assert.Equal(t, recorder.Code, http.StatusUnauthorized)
+		assert.Equal(t, recorder.Header().Get("Content-Type"), ProblemContentType)
+		problem := &Problem{}
+		assert.Equal(t, json.Unmarshal(recorder.Body.Bytes(), problem), nil)
+		assert.Equal(t, problem, &Problem{Type: "about:blank", Title: "Device validation failed", Status: http.StatusUnauthorized, Detail: ErrMissingDevice.Error(), Instance: "/account"})
+	})
+	t.Run("Unknown device", func(t *testing.T) {
+		recorder := serve(Config{}, request("UNKNOWN", "KOALA_PANDA"))
+		assert.Equal(t, recorder.Code, http.StatusUnauthorized)
+		assert.NotContains(t, recorder.Body.String(), "DEVICE_NOT_FOUND")
+	})
+	t.Run("Denied by policy", func(t *testing.T) {
+		recorder := serve(Config{Policy: policy.Default()}, request(fazpassId, "ROOTED"))
+		assert.Equal(t, recorder.Code, http.StatusForbidden)
+		problem := &Problem{}
+		json.Unmarshal(recorder.Body.Bytes(), problem)
+		assert.Equal(t, problem.Reasons, []string{policy.ReasonRooted})
+	})
+	t.Run("Challenged by policy", func(t *testing.T) {
+		recorder := serve(Config{Policy: policy.Default()}, request(fazpassId, "VPN"))
+		assert.Equal(t, recorder.Code, http.StatusOK)
+		assert.Equal(t, recorder.Body.String(), fazpassId+" challenge")
+	})
+	t.Run("Fazpass unavailable", func(t *testing.T) {
+		server.FailNext("/validate", http.StatusServiceUnavailable, fazpass.ErrorResponse{Code: "UNAVAILABLE"})
+		recorder := serve(Config{}, request(fazpassId, "KOALA_PANDA"))
+		assert.Equal(t, recorder.Code, http.StatusServiceUnavailable)
+	})
+	t.Run("Custom error handler", func(t *testing.T) {
+		var handled error
+		recorder := serve(Config{ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			handled = err
+			w.WriteHeader(http.StatusPaymentRequired)
+		}}, request("", ""))
+		assert.Equal(t, recorder.Code, http.StatusPaymentRequired)
+		assert.True(t, errors.Is(handled, ErrMissingDevice))
+	})
+}
+
+func TestStatusCode(t *testing.T) {
+	assert.Equal(t, StatusCode(ErrMissingDevice), http.StatusUnauthorized)
+	assert.Equal(t, StatusCode(&DeniedError{}), http.StatusForbidden)
+	assert.Equal(t, StatusCode(&fazpass.APIError{StatusCode: http.StatusNotFound}), http.StatusUnauthorized)
+	assert.Equal(t, StatusCode(&fazpass.APIError{StatusCode: http.StatusBadRequest, Code: "DEVICE_NOT_FOUND"}), http.StatusUnauthorized)
+	assert.Equal(t, StatusCode(&fazpass.APIError{StatusCode: http.StatusUnauthorized}), http.StatusInternalServerError)
+	assert.Equal(t, StatusCode(&fazpass.APIError{StatusCode: http.StatusForbidden}), http.StatusInternalServerError)
+	assert.Equal(t, StatusCode(&fazpass.APIError{StatusCode: http.StatusTooManyRequests}), http.StatusServiceUnavailable)
+	assert.Equal(t, StatusCode(context.Canceled), http.StatusServiceUnavailable)
+	assert.Equal(t, StatusCode(fmt.Errorf("%w: timeout", fazpass.ErrTransport)), http.StatusServiceUnavailable)
+	assert.Equal(t, StatusCode(fazpass.ErrDecrypt), http.StatusInternalServerError)
+}
@@ -0,0 +1,52 @@
+package fazpasshttp
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// ProblemContentType is the media type of an RFC 7807 problem.
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem detail, with the reason codes of a denied
+// device as extension.
+type Problem struct {
+	Type     string   `json:"type,omitempty"`
+	Title    string   `json:"title"`
+	Status   int      `json:"status"`
+	Detail   string   `json:"detail,omitempty"`
+	Instance string   `json:"instance,omitempty"`
+	Reasons  []string `json:"reasons,omitempty"`
+}
+
+// NewProblem describes err, with the status given by StatusCode. Only the
+// reasons of a denial are disclosed, other errors are summed up by the title
+// so Fazpass details do not leak to the caller.
+func NewProblem(r *http.Request, err error) *Problem {
+	status := StatusCode(err)
+	problem := &Problem{
+		Type:     "about:blank",
+		Title:    problemTitle(status),
+		Status:   status,
+		Instance: r.URL.Path,
+	}
+	denied := &DeniedError{}
+	switch {
+	case errors.As(err, &denied):
+		problem.Reasons = denied.Verdict.Reasons
+	case errors.Is(err, ErrMissingDevice):
+		problem.Detail = err.Error()
+	}
+	return problem
+}
+
+// WriteProblem is the default ErrorHandler, it responds with the problem+json
+// body of NewProblem.
+func WriteProblem(w http.ResponseWriter, r *http.Request, err error) {
+	problem := NewProblem(r, err)
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(problem.Status)
+	json.NewEncoder(w).Encode(problem)
+}
@@ -0,0 +1,26 @@
+package fazpasshttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	fazpass "github.com/anvarisy/go-fazpass-sdk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteProblem(t *testing.T) {
+	t.Run("Denied", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		WriteProblem(recorder, httptest.NewRequest("POST", "/transfer", nil), &DeniedError{Verdict: fazpass.Verdict{Decision: fazpass.Deny, Reasons: []string{"rooted", "emulator"}}})
+		assert.Equal(t, recorder.Code, http.StatusForbidden)
+		assert.Equal(t, recorder.Header().Get("Content-Type"), ProblemContentType)
+		assert.JSONEq(t, recorder.Body.String(), `{"type":"about:blank","title":"Device denied","status":403,"instance":"/transfer","reasons":["rooted","emulator"]}`)
+	})
+	t.Run("Internal details hidden", func(t *testing.T) {
+		problem := NewProblem(httptest.NewRequest("GET", "/", nil), &fazpass.APIError{StatusCode: 500, Code: "INTERNAL", Message: "database down"})
+		assert.Equal(t, problem.Status, http.StatusServiceUnavailable)
+		assert.Equal(t, problem.Title, "Device cannot be validated: service unavailable")
+		assert.Equal(t, problem.Detail, "")
+	})
+}
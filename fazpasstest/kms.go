@@ -0,0 +1,134 @@
+package fazpasstest
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+// Decryption algorithms understood by a KMS.
+const (
+	AlgorithmPKCS1v15   = "RSAES_PKCS1_V1_5"
+	AlgorithmOAEPSHA256 = "RSAES_OAEP_SHA_256"
+)
+
+// KMS is a local stand-in for a key management service: it holds a private
+// key and decrypts on request, so tests can check the key never reaches the
+// client.
+type KMS struct {
+	*httptest.Server
+
+	key   *rsa.PrivateKey
+	mu    sync.Mutex
+	calls int
+}
+
+// DecryptRequest is the body of a KMS /decrypt call.
+type DecryptRequest struct {
+	Algorithm  string `json:"algorithm"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// DecryptResponse is the body of a successful KMS /decrypt call.
+type DecryptResponse struct {
+	Plaintext []byte `json:"plaintext"`
+}
+
+// NewKMS starts a KMS holding key, to be closed by the caller.
+func NewKMS(key *rsa.PrivateKey) *KMS {
+	k := &KMS{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/decrypt", k.decrypt)
+	k.Server = httptest.NewServer(mux)
+	return k
+}
+
+// Calls returns how many decryptions the KMS served.
+func (k *KMS) Calls() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.calls
+}
+
+// Decrypter returns a crypto.Decrypter delegating to the KMS.
+func (k *KMS) Decrypter() crypto.Decrypter {
+	return &remoteDecrypter{url: k.URL + "/decrypt", public: &k.key.PublicKey, client: k.Client()}
+}
+
+func (k *KMS) decrypt(w http.ResponseWriter, r *http.Request) {
+	request := &DecryptRequest{}
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	k.mu.Lock()
+	k.calls++
+	k.mu.Unlock()
+	var plaintext []byte
+	var err error
+	switch request.Algorithm {
+	case AlgorithmPKCS1v15:
+		plaintext, err = rsa.DecryptPKCS1v15(rand.Reader, k.key, request.Ciphertext)
+	case AlgorithmOAEPSHA256:
+		plaintext, err = rsa.DecryptOAEP(crypto.SHA256.New(), rand.Reader, k.key, request.Ciphertext, nil)
+	default:
+		http.Error(w, "unsupported algorithm", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "decryption failed", http.StatusUnprocessableEntity)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&DecryptResponse{Plaintext: plaintext})
+}
+
+type remoteDecrypter struct {
+	url    string
+	public *rsa.PublicKey
+	client *http.Client
+}
+
+func (d *remoteDecrypter) Public() crypto.PublicKey {
+	return d.public
+}
+
+func (d *remoteDecrypter) Decrypt(_ io.Reader, msg []byte, opts crypto.DecrypterOpts) ([]byte, error) {
+	request := &DecryptRequest{Algorithm: AlgorithmPKCS1v15, Ciphertext: msg}
+	switch opts := opts.(type) {
+	case nil, *rsa.PKCS1v15DecryptOptions:
+	case *rsa.OAEPOptions:
+		if opts.Hash != crypto.SHA256 || len(opts.Label) > 0 {
+			return nil, errors.New("kms: only OAEP with SHA-256 and no label is supported")
+		}
+		request.Algorithm = AlgorithmOAEPSHA256
+	default:
+		return nil, fmt.Errorf("kms: unsupported decrypter options %T", opts)
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	response, err := d.client.Post(d.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(response.Body)
+		return nil, fmt.Errorf("kms: %s: %s", response.Status, bytes.TrimSpace(message))
+	}
+	decrypted := &DecryptResponse{}
+	if err = json.NewDecoder(response.Body).Decode(decrypted); err != nil {
+		return nil, err
+	}
+	return decrypted.Plaintext, nil
+}
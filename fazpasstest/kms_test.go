@@ -0,0 +1,31 @@
+package fazpasstest_test
+
+import (
+	"testing"
+
+	fazpass "github.com/anvarisy/go-fazpass-sdk"
+	"github.com/anvarisy/go-fazpass-sdk/fazpasstest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKMS(t *testing.T) {
+	server := fazpasstest.NewServer()
+	defer server.Close()
+	kms := fazpasstest.NewKMS(server.ClientPrivateKey)
+	defer kms.Close()
+
+	for _, mode := range []fazpass.EncryptionMode{fazpass.EncryptionRSA, fazpass.EncryptionHybrid} {
+		t.Run(string(mode), func(t *testing.T) {
+			f, err := server.Client(
+				fazpass.WithDecrypter(kms.Decrypter()),
+				fazpass.WithFlowOptions(fazpass.WithEncryptionMode(mode)),
+			)
+			assert.Equal(t, err, nil)
+			calls := kms.Calls()
+			data, err := f.Check("anvarisy@gmail.com", "085811752000", "KOALA_PANDA")
+			assert.Equal(t, err, nil)
+			assert.NotEqual(t, data.SessionId, "")
+			assert.Equal(t, kms.Calls(), calls+1)
+		})
+	}
+}
@@ -0,0 +1,314 @@
+// Package fazpasstest provides an in-process fake of the Fazpass API for
+// integration tests.
+package fazpasstest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	fazpass "github.com/anvarisy/go-fazpass-sdk"
+	"github.com/anvarisy/go-fazpass-sdk/utils"
+)
+
+// MerchantKey is the bearer token accepted by a Server.
+const MerchantKey = "FAZPASSTEST_MERCHANT_KEY"
+
+// Server fakes the Fazpass /check, /enroll, /validate and /remove endpoints.
+// Requests are decrypted with a generated server key and responses are
+// encrypted for a generated merchant key, both exposed so a client can be
+// wired to it, see Client.
+type Server struct {
+	*httptest.Server
+
+	// ServerPublicKey encrypts requests, it plays the Fazpass public key.
+	ServerPublicKey *rsa.PublicKey
+	// ClientPrivateKey decrypts responses, it plays the merchant private key.
+	ClientPrivateKey *rsa.PrivateKey
+
+	serverKey *rsa.PrivateKey
+
+	mu            sync.Mutex
+	sequence      int
+	devices       map[string]*enrollment
+	accounts      map[string]string
+	profiles      map[string]fazpass.Device
+	defaultDevice fazpass.Device
+	failures      map[string][]failure
+}
+
+type enrollment struct {
+	email  string
+	phone  string
+	device fazpass.Device
+}
+
+type failure struct {
+	status   int
+	response fazpass.ErrorResponse
+}
+
+// NewServer starts a fake Fazpass server, to be closed by the caller.
+func NewServer() *Server {
+	serverKey, err := utils.GenerateKeyPair(2048)
+	if err != nil {
+		panic("fazpasstest: " + err.Error())
+	}
+	clientKey, err := utils.GenerateKeyPair(2048)
+	if err != nil {
+		panic("fazpasstest: " + err.Error())
+	}
+	s := &Server{
+		ServerPublicKey:  &serverKey.PublicKey,
+		ClientPrivateKey: clientKey,
+		serverKey:        serverKey,
+		devices:          map[string]*enrollment{},
+		accounts:         map[string]string{},
+		profiles:         map[string]fazpass.Device{},
+		failures:         map[string][]failure{},
+		defaultDevice:    fazpass.Device{Score: 1, Platform: "android", Name: "Fazpass Test Device"},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/check", s.handle(s.check))
+	mux.HandleFunc("/enroll", s.handle(s.enroll))
+	mux.HandleFunc("/validate", s.handle(s.validate))
+	mux.HandleFunc("/remove", s.handle(s.remove))
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Client returns a client wired to the server, opts are applied last.
+func (s *Server) Client(opts ...fazpass.Option) (*fazpass.Fazpass, error) {
+	defaults := []fazpass.Option{
+		fazpass.WithKeys(s.ClientPrivateKey, s.ServerPublicKey),
+		fazpass.WithMerchantKey(MerchantKey),
+		fazpass.WithBaseURL(s.URL),
+	}
+	return fazpass.New(append(defaults, opts...)...)
+}
+
+// SetDevice scripts the device reported for requests carrying encData.
+func (s *Server) SetDevice(encData string, device fazpass.Device) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.profiles[encData] = device
+}
+
+// SetDefaultDevice scripts the device reported for any other encData.
+func (s *Server) SetDefaultDevice(device fazpass.Device) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.defaultDevice = device
+}
+
+// FailNext makes the next request to path, e.g. "/check", fail with status.
+// Calls queue up, one failure per request.
+func (s *Server) FailNext(path string, status int, response fazpass.ErrorResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[path] = append(s.failures[path], failure{status: status, response: response})
+}
+
+// FazpassId returns the fazpass id enrolled for email and phone.
+func (s *Server) FazpassId(email string, phone string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id, ok := s.accounts[account(email, phone)]
+	return id, ok
+}
+
+func account(email string, phone string) string {
+	return email + "|" + phone
+}
+
+type endpoint func(body []byte) (*fazpass.Data, int, *fazpass.ErrorResponse)
+
+func (s *Server) handle(next endpoint) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestId := s.nextId("request")
+		w.Header().Set("X-Request-Id", requestId)
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, &fazpass.ErrorResponse{Code: "METHOD_NOT_ALLOWED", Message: "method not allowed"})
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer "+MerchantKey {
+			writeError(w, http.StatusUnauthorized, &fazpass.ErrorResponse{Code: "UNAUTHORIZED", Message: "invalid merchant key"})
+			return
+		}
+		if f, ok := s.nextFailure(r.URL.Path); ok {
+			f.response.RequestID = requestId
+			writeError(w, f.status, &f.response)
+			return
+		}
+		transmission := &fazpass.Transmission{}
+		if err := json.NewDecoder(r.Body).Decode(transmission); err != nil {
+			writeError(w, http.StatusBadRequest, &fazpass.ErrorResponse{Code: "BAD_REQUEST", Message: "body is not a transmission"})
+			return
+		}
+		body, err := s.decrypt(transmission)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, &fazpass.ErrorResponse{Code: "BAD_REQUEST", Message: "cannot decrypt message"})
+			return
+		}
+		data, status, errorResponse := next(body)
+		if errorResponse != nil {
+			errorResponse.RequestID = requestId
+			writeError(w, status, errorResponse)
+			return
+		}
+		response, err := s.encrypt(transmission.Mode, data)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, &fazpass.ErrorResponse{Code: "INTERNAL", Message: err.Error()})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+func (s *Server) check(body []byte) (*fazpass.Data, int, *fazpass.ErrorResponse) {
+	request := &fazpass.CheckRequest{}
+	if err := json.Unmarshal(body, request); err != nil || request.Data == "" {
+		return nil, http.StatusBadRequest, &fazpass.ErrorResponse{Code: "BAD_REQUEST", Message: "invalid check request"}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	device := s.device(request.Data)
+	device.FazpassId = s.accounts[account(request.Email, request.Phone)]
+	return s.data(device), http.StatusOK, nil
+}
+
+func (s *Server) enroll(body []byte) (*fazpass.Data, int, *fazpass.ErrorResponse) {
+	request := &fazpass.EnrollRequest{}
+	if err := json.Unmarshal(body, request); err != nil || request.Data == "" {
+		return nil, http.StatusBadRequest, &fazpass.ErrorResponse{Code: "BAD_REQUEST", Message: "invalid enroll request"}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := account(request.Email, request.Phone)
+	id, ok := s.accounts[key]
+	if !ok {
+		s.sequence++
+		id = fmt.Sprintf("FAZPASS-%06d", s.sequence)
+		s.accounts[key] = id
+	}
+	device := s.device(request.Data)
+	device.FazpassId = id
+	s.devices[id] = &enrollment{email: request.Email, phone: request.Phone, device: device}
+	return s.data(device), http.StatusOK, nil
+}
+
+func (s *Server) validate(body []byte) (*fazpass.Data, int, *fazpass.ErrorResponse) {
+	request := &fazpass.ValidateRequest{}
+	if err := json.Unmarshal(body, request); err != nil || request.FazpassId == "" {
+		return nil, http.StatusBadRequest, &fazpass.ErrorResponse{Code: "BAD_REQUEST", Message: "invalid validate request"}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.devices[request.FazpassId]; !ok {
+		return nil, http.StatusNotFound, &fazpass.ErrorResponse{Code: "DEVICE_NOT_FOUND", Message: "device is not enrolled"}
+	}
+	device := s.device(request.Data)
+	device.FazpassId = request.FazpassId
+	return s.data(device), http.StatusOK, nil
+}
+
+func (s *Server) remove(body []byte) (*fazpass.Data, int, *fazpass.ErrorResponse) {
+	request := &fazpass.RemoveRequest{}
+	if err := json.Unmarshal(body, request); err != nil || request.FazpassId == "" {
+		return nil, http.StatusBadRequest, &fazpass.ErrorResponse{Code: "BAD_REQUEST", Message: "invalid remove request"}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	enrolled, ok := s.devices[request.FazpassId]
+	if !ok {
+		return nil, http.StatusNotFound, &fazpass.ErrorResponse{Code: "DEVICE_NOT_FOUND", Message: "device is not enrolled"}
+	}
+	delete(s.devices, request.FazpassId)
+	delete(s.accounts, account(enrolled.email, enrolled.phone))
+	return s.data(enrolled.device), http.StatusOK, nil
+}
+
+// device returns the scripted device for encData, s.mu must be held.
+func (s *Server) device(encData string) fazpass.Device {
+	if device, ok := s.profiles[encData]; ok {
+		return device
+	}
+	return s.defaultDevice
+}
+
+// data wraps device into a fresh session, s.mu must be held.
+func (s *Server) data(device fazpass.Device) *fazpass.Data {
+	s.sequence++
+	now := time.Now().UTC()
+	return &fazpass.Data{
+		SessionId: fmt.Sprintf("SESSION-%06d", s.sequence),
+		TimeStamp: &now,
+		Device:    device,
+	}
+}
+
+func (s *Server) nextId(prefix string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sequence++
+	return fmt.Sprintf("%s-%06d", strings.ToUpper(prefix), s.sequence)
+}
+
+func (s *Server) nextFailure(path string) (failure, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	queued := s.failures[path]
+	if len(queued) == 0 {
+		return failure{}, false
+	}
+	s.failures[path] = queued[1:]
+	return queued[0], true
+}
+
+func (s *Server) decrypt(transmission *fazpass.Transmission) ([]byte, error) {
+	ciphertext, err := base64.StdEncoding.DecodeString(transmission.Message)
+	if err != nil {
+		return nil, err
+	}
+	if transmission.Mode == fazpass.EncryptionHybrid {
+		return utils.DecryptEnvelope(ciphertext, s.serverKey)
+	}
+	return rsa.DecryptPKCS1v15(rand.Reader, s.serverKey, ciphertext)
+}
+
+// encrypt answers in the mode of the request, switching to the hybrid mode
+// when the payload does not fit in a plain RSA block.
+func (s *Server) encrypt(mode fazpass.EncryptionMode, data *fazpass.Data) (*fazpass.Transmission, error) {
+	plaintext, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	clientKey := &s.ClientPrivateKey.PublicKey
+	if mode != fazpass.EncryptionHybrid && len(plaintext) > clientKey.Size()-11 {
+		mode = fazpass.EncryptionHybrid
+	}
+	var ciphertext []byte
+	if mode == fazpass.EncryptionHybrid {
+		ciphertext, err = utils.EncryptEnvelope(plaintext, clientKey)
+	} else {
+		ciphertext, err = utils.EncryptWithPublicKey(plaintext, clientKey)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &fazpass.Transmission{Message: base64.StdEncoding.EncodeToString(ciphertext), Mode: mode}, nil
+}
+
+func writeError(w http.ResponseWriter, status int, response *fazpass.ErrorResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,81 @@
+package fazpasstest_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	fazpass "github.com/anvarisy/go-fazpass-sdk"
+	"github.com/anvarisy/go-fazpass-sdk/fazpasstest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer(t *testing.T) {
+	server := fazpasstest.NewServer()
+	defer server.Close()
+
+	t.Run("Device lifecycle", func(t *testing.T) {
+		f, err := server.Client()
+		assert.Equal(t, err, nil)
+
+		checked, err := f.Check("anvarisy@gmail.com", "085811752000", "KOALA_PANDA")
+		assert.Equal(t, err, nil)
+		assert.Equal(t, checked.Device.FazpassId, "")
+		assert.NotEqual(t, checked.SessionId, "")
+
+		enrolled, err := f.EnrollDevice("anvarisy@gmail.com", "085811752000", "KOALA_PANDA")
+		assert.Equal(t, err, nil)
+		id, _ := server.FazpassId("anvarisy@gmail.com", "085811752000")
+		assert.Equal(t, enrolled.Device.FazpassId, id)
+
+		checked, _ = f.Check("anvarisy@gmail.com", "085811752000", "KOALA_PANDA")
+		assert.Equal(t, checked.Device.FazpassId, id)
+
+		validated, err := f.ValidateDevice(id, "KOALA_PANDA")
+		assert.Equal(t, err, nil)
+		assert.Equal(t, validated.Device.FazpassId, id)
+
+		_, err = f.RemoveDevice(id, "KOALA_PANDA")
+		assert.Equal(t, err, nil)
+
+		_, err = f.ValidateDevice(id, "KOALA_PANDA")
+		apiErr := &fazpass.APIError{}
+		assert.True(t, errors.As(err, &apiErr))
+		assert.Equal(t, apiErr.StatusCode, http.StatusNotFound)
+		assert.Equal(t, apiErr.Code, "DEVICE_NOT_FOUND")
+		assert.NotEqual(t, apiErr.RequestID, "")
+	})
+	t.Run("Scripted device", func(t *testing.T) {
+		server.SetDevice("ROOTED", fazpass.Device{IsRooted: true, IsVpn: true, Score: 0.2})
+		f, _ := server.Client()
+		data, err := f.Check("anvarisy@gmail.com", "085811752000", "ROOTED")
+		assert.Equal(t, err, nil)
+		assert.True(t, data.Device.IsRooted)
+		assert.True(t, data.Device.IsVpn)
+		assert.Equal(t, data.Device.Score, 0.2)
+	})
+	t.Run("Hybrid encryption", func(t *testing.T) {
+		f, _ := server.Client(fazpass.WithFlowOptions(fazpass.WithEncryptionMode(fazpass.EncryptionHybrid)))
+		data, err := f.Check("anvarisy@gmail.com", "085811752000", string(make([]byte, 1024)))
+		assert.Equal(t, err, nil)
+		assert.NotEqual(t, data.SessionId, "")
+	})
+	t.Run("Wrong merchant key", func(t *testing.T) {
+		f, _ := server.Client(fazpass.WithMerchantKey("MERCHANT_KEY"))
+		_, err := f.Check("anvarisy@gmail.com", "085811752000", "KOALA_PANDA")
+		apiErr := &fazpass.APIError{}
+		assert.True(t, errors.As(err, &apiErr))
+		assert.Equal(t, apiErr.StatusCode, http.StatusUnauthorized)
+	})
+	t.Run("Scripted failure", func(t *testing.T) {
+		server.FailNext("/check", http.StatusServiceUnavailable, fazpass.ErrorResponse{Code: "UNAVAILABLE"})
+		f, _ := server.Client()
+		_, err := f.Check("anvarisy@gmail.com", "085811752000", "KOALA_PANDA")
+		apiErr := &fazpass.APIError{}
+		assert.True(t, errors.As(err, &apiErr))
+		assert.Equal(t, apiErr.Code, "UNAVAILABLE")
+
+		_, err = f.Check("anvarisy@gmail.com", "085811752000", "KOALA_PANDA")
+		assert.Equal(t, err, nil)
+	})
+}
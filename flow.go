@@ -2,21 +2,58 @@ package fazpass
 
 import (
 	"bytes"
+	"context"
+	"crypto"
 	"crypto/rsa"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/anvarisy/go-fazpass-sdk/utils"
 	"io"
 	"net/http"
+	"strings"
+	"time"
 )
 
 func Default() FlowInterface {
-	return &Flow{}
+	return NewFlow()
 }
 
+// FlowOption configures a Flow built by NewFlow.
+type FlowOption func(flow *Flow)
+
+// NewFlow returns the default flow configured with opts. The flow keeps a
+// single http client, so connections are reused across requests.
+func NewFlow(opts ...FlowOption) FlowContextInterface {
+	flow := &Flow{timeout: DefaultTimeout}
+	for _, opt := range opts {
+		opt(flow)
+	}
+	if flow.client == nil {
+		flow.client = flow.newClient()
+	}
+	return flow
+}
+
+// WithEncryptionMode selects how request payloads are encrypted. Responses are
+// decrypted according to the mode announced by the server, falling back to
+// this one.
+func WithEncryptionMode(mode EncryptionMode) FlowOption {
+	return func(flow *Flow) {
+		flow.mode = mode
+	}
+}
+
+// maxErrorMessage bounds how much of a non-JSON error body ends up in an APIError.
+const maxErrorMessage = 512
+
 type Flow struct {
-	client *http.Client
+	client    *http.Client
+	timeout   time.Duration
+	transport transportOptions
+	mode      EncryptionMode
+	retry     *RetryPolicy
 }
 
 type FlowInterface interface {
@@ -25,48 +62,232 @@ type FlowInterface interface {
 	ExtractingData(privKey *rsa.PrivateKey, response *http.Response, data *Data) (*Data, error)
 }
 
+// FlowContextInterface is a FlowInterface whose steps take a context, which
+// cancels them and carries request values such as the idempotency key.
+// Fazpass uses these steps when its Flow implements them. A flow implementing
+// only FlowInterface is given the private key, or nil when responses are
+// decrypted by a crypto.Decrypter which is not an *rsa.PrivateKey.
+type FlowContextInterface interface {
+	FlowInterface
+	WrappingDataContext(ctx context.Context, pubKey *rsa.PublicKey, model interface{}) ([]byte, error)
+	SendingDataContext(ctx context.Context, baseUrl string, wrappedMessage []byte, merchantKey string) (*http.Response, error)
+	ExtractingDataContext(ctx context.Context, decrypter crypto.Decrypter, response *http.Response, data *Data) (*Data, error)
+}
+
+// withContext returns flow as a FlowContextInterface, adapting a flow without
+// context steps.
+func withContext(flow FlowInterface) FlowContextInterface {
+	if flow, ok := flow.(FlowContextInterface); ok {
+		return flow
+	}
+	return contextFlow{flow}
+}
+
+// contextFlow runs the steps of a FlowInterface, ignoring their context.
+type contextFlow struct {
+	FlowInterface
+}
+
+func (flow contextFlow) WrappingDataContext(ctx context.Context, pubKey *rsa.PublicKey, model interface{}) ([]byte, error) {
+	return flow.WrappingData(pubKey, model)
+}
+
+func (flow contextFlow) SendingDataContext(ctx context.Context, baseUrl string, wrappedMessage []byte, merchantKey string) (*http.Response, error) {
+	return flow.SendingData(baseUrl, wrappedMessage, merchantKey)
+}
+
+func (flow contextFlow) ExtractingDataContext(ctx context.Context, decrypter crypto.Decrypter, response *http.Response, data *Data) (*Data, error) {
+	privKey, _ := decrypter.(*rsa.PrivateKey)
+	return flow.ExtractingData(privKey, response, data)
+}
+
 func (flow *Flow) WrappingData(pubKey *rsa.PublicKey, model interface{}) ([]byte, error) {
-	var (
-		err        error
-		marshalled []byte
-		encrypted  []byte
-		wrapedData []byte
-	)
-	marshalled, err = json.Marshal(model)
-	encrypted, err = utils.EncryptWithPublicKey(marshalled, pubKey)
-	message := base64.StdEncoding.EncodeToString([]byte(encrypted))
-	wrapedData, err = json.Marshal(&Transmission{Message: message})
-	return wrapedData, err
+	return flow.WrappingDataContext(context.Background(), pubKey, model)
+}
+
+func (flow *Flow) WrappingDataContext(ctx context.Context, pubKey *rsa.PublicKey, model interface{}) ([]byte, error) {
+	if pubKey == nil {
+		return nil, fmt.Errorf("%w: no public key", ErrInvalidKey)
+	}
+	marshalled, err := json.Marshal(model)
+	if err != nil {
+		return nil, err
+	}
+	transmission := &Transmission{}
+	var encrypted []byte
+	switch flow.mode {
+	case EncryptionHybrid:
+		encrypted, err = utils.EncryptEnvelope(marshalled, pubKey)
+		transmission.Mode = EncryptionHybrid
+	default:
+		encrypted, err = utils.EncryptWithPublicKey(marshalled, pubKey)
+	}
+	if err != nil {
+		return nil, err
+	}
+	transmission.Message = base64.StdEncoding.EncodeToString(encrypted)
+	return json.Marshal(transmission)
 }
 
 func (flow *Flow) SendingData(baseUrl string, wrappedMessage []byte, merchantKey string) (*http.Response, error) {
-	client := &http.Client{}
-	request, err := http.NewRequest("POST", baseUrl, bytes.NewReader(wrappedMessage))
+	return flow.SendingDataContext(context.Background(), baseUrl, wrappedMessage, merchantKey)
+}
+
+func (flow *Flow) SendingDataContext(ctx context.Context, baseUrl string, wrappedMessage []byte, merchantKey string) (*http.Response, error) {
+	idempotencyKey := idempotencyKeyFrom(ctx)
+	attempts := flow.retry.attempts(baseUrl, idempotencyKey != "")
+	for attempt := 1; ; attempt++ {
+		response, err := flow.post(ctx, baseUrl, wrappedMessage, merchantKey, idempotencyKey)
+		if attempt >= attempts || !flow.retry.retryable(response, err) {
+			return response, err
+		}
+		delay, ok := flow.retry.delay(attempt, response)
+		if !ok {
+			return response, err
+		}
+		discard(response)
+		if err := wait(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (flow *Flow) post(ctx context.Context, baseUrl string, wrappedMessage []byte, merchantKey string, idempotencyKey string) (*http.Response, error) {
+	client := flow.client
+	if client == nil {
+		client = defaultClient
+	}
+	request, err := http.NewRequestWithContext(ctx, "POST", baseUrl, bytes.NewReader(wrappedMessage))
 	if err != nil {
 		return nil, err
 	}
 	request.Header.Set("Content-Type", "application/json")
 	request.Header.Set("Authorization", "Bearer "+merchantKey)
+	if idempotencyKey != "" {
+		request.Header.Set("Idempotency-Key", idempotencyKey)
+	}
 	response, err := client.Do(request)
 	if err != nil {
-		return nil, err
+		// Report cancellation as the bare context error so callers can tell it
+		// apart from a transport failure.
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, fmt.Errorf("%w: %w", ErrTransport, err)
 	}
 	return response, nil
 }
 
+// ExtractingData is like ExtractingDataContext with a private key.
 func (flow *Flow) ExtractingData(privKey *rsa.PrivateKey, response *http.Response, data *Data) (*Data, error) {
+	return flow.ExtractingDataContext(context.Background(), privKey, response, data)
+}
+
+// ExtractingDataContext decodes and decrypts the response into data, the
+// decrypter being typically a *rsa.PrivateKey or a key held by a KMS. A nil
+// error always means data holds a successfully decrypted payload; a non-2xx
+// status is reported as an *APIError.
+func (flow *Flow) ExtractingDataContext(ctx context.Context, decrypter crypto.Decrypter, response *http.Response, data *Data) (*Data, error) {
 	defer response.Body.Close()
-	messageBodyResponse, _ := io.ReadAll(response.Body)
-	transmissionResponse := &Transmission{}
-	json.Unmarshal(messageBodyResponse, transmissionResponse)
-	decryptMessage, err := base64.StdEncoding.DecodeString(string(transmissionResponse.Message))
-
-	decrypted, _ := utils.DecryptWithPrivateKey(decryptMessage, privKey)
-	json.Unmarshal(decrypted, data)
-	fmt.Print(data.SessionId)
+	body, err := io.ReadAll(response.Body)
 	if err != nil {
-		return data, err
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return data, ctxErr
+		}
+		return data, fmt.Errorf("%w: %w", ErrTransport, err)
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return data, flow.apiError(decrypter, response, body)
 	}
 
+	transmission := &Transmission{}
+	if err = json.Unmarshal(body, transmission); err != nil {
+		return data, fmt.Errorf("%w: %w", ErrDecrypt, err)
+	}
+	decrypted, err := flow.open(transmission, decrypter)
+	if err != nil {
+		return data, err
+	}
+	decoded := &Data{}
+	if err = json.Unmarshal(decrypted, decoded); err != nil {
+		return data, fmt.Errorf("%w: %w", ErrDecrypt, err)
+	}
+	*data = *decoded
 	return data, nil
 }
+
+// open decodes and decrypts the message carried by transmission.
+func (flow *Flow) open(transmission *Transmission, decrypter crypto.Decrypter) ([]byte, error) {
+	if transmission.Message == "" {
+		return nil, fmt.Errorf("%w: empty message", ErrDecrypt)
+	}
+	if isNil(decrypter) {
+		return nil, fmt.Errorf("%w: no private key", ErrDecrypt)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(transmission.Message)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDecrypt, err)
+	}
+	decrypted, err := flow.decrypt(transmission.Mode, ciphertext, decrypter)
+	if err != nil {
+		if errors.Is(err, ErrDecrypt) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %w", ErrDecrypt, err)
+	}
+	return decrypted, nil
+}
+
+// apiError builds an *APIError from an error response, whose body is either a
+// plain ErrorResponse, an encrypted one, or not JSON at all.
+func (flow *Flow) apiError(decrypter crypto.Decrypter, response *http.Response, body []byte) error {
+	apiErr := &APIError{
+		StatusCode: response.StatusCode,
+		RequestID:  response.Header.Get("X-Request-Id"),
+	}
+	errorResponse := &ErrorResponse{}
+	transmission := &Transmission{}
+	if json.Unmarshal(body, transmission) == nil && !isNil(decrypter) {
+		if decrypted, err := flow.open(transmission, decrypter); err == nil {
+			body = decrypted
+		}
+	}
+	if err := json.Unmarshal(body, errorResponse); err == nil {
+		apiErr.Code = errorResponse.Code
+		apiErr.Message = errorResponse.Message
+		if errorResponse.RequestID != "" {
+			apiErr.RequestID = errorResponse.RequestID
+		}
+	} else {
+		apiErr.Message = strings.TrimSpace(string(body))
+		if len(apiErr.Message) > maxErrorMessage {
+			apiErr.Message = apiErr.Message[:maxErrorMessage]
+		}
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = http.StatusText(response.StatusCode)
+	}
+	return apiErr
+}
+
+func (flow *Flow) decrypt(mode EncryptionMode, ciphertext []byte, decrypter crypto.Decrypter) ([]byte, error) {
+	if mode == "" {
+		mode = flow.mode
+	}
+	switch mode {
+	case EncryptionHybrid:
+		return utils.DecryptEnvelope(ciphertext, decrypter)
+	case EncryptionRSA, "":
+		return utils.DecryptWithDecrypter(ciphertext, decrypter)
+	default:
+		return nil, fmt.Errorf("%w: unsupported encryption mode %q", ErrDecrypt, mode)
+	}
+}
+
+// isNil tells whether decrypter is nil, including a nil *rsa.PrivateKey.
+func isNil(decrypter crypto.Decrypter) bool {
+	if key, ok := decrypter.(*rsa.PrivateKey); ok {
+		return key == nil
+	}
+	return decrypter == nil
+}
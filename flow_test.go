@@ -1,13 +1,17 @@
 package fazpass
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"github.com/anvarisy/go-fazpass-sdk/utils"
 	"github.com/jarcoal/httpmock"
 	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -19,6 +23,12 @@ func TestWrappingData(t *testing.T) {
 		_, err := f.WrappingData(pubKey, &Data{})
 		assert.Equal(t, err, nil)
 	})
+
+	t.Run("Missing public key", func(t *testing.T) {
+		f := Default()
+		_, err := f.WrappingData(nil, &Data{})
+		assert.Equal(t, errors.Is(err, ErrInvalidKey), true)
+	})
 }
 
 func TestSendingData(t *testing.T) {
@@ -44,6 +54,16 @@ func TestSendingData(t *testing.T) {
 		_, err := f.SendingData(baseUrl, marshalled, "MERCHANT_KEY")
 		assert.Equal(t, err, nil)
 	})
+	t.Run("Sending data canceled", func(t *testing.T) {
+		f := NewFlow()
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer server.Close()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		marshalled, _ := json.Marshal(&Data{})
+		_, err := f.SendingDataContext(ctx, server.URL+"/check", marshalled, "MERCHANT_KEY")
+		assert.True(t, errors.Is(err, context.Canceled))
+	})
 }
 
 func TestExtractingData(t *testing.T) {
@@ -60,8 +80,101 @@ func TestExtractingData(t *testing.T) {
 		marshalled, _ = json.Marshal(&Data{})
 		encrypted, _ = utils.EncryptWithPublicKey(marshalled, pubKey)
 		message := base64.StdEncoding.EncodeToString([]byte(encrypted))
-		resp, _ := httpmock.NewJsonResponse(200, &Transmission{message})
-		data, _ := f.ExtractingData(privKey, resp, &Data{})
+		resp, _ := httpmock.NewJsonResponse(200, &Transmission{Message: message})
+		data, err := f.ExtractingData(privKey, resp, &Data{})
+		assert.Equal(t, err, nil)
 		assert.Equal(t, data.SessionId, "")
 	})
+	t.Run("Error response", func(t *testing.T) {
+		f := Default()
+		resp, _ := httpmock.NewJsonResponse(401, &ErrorResponse{Code: "UNAUTHORIZED", Message: "invalid merchant key"})
+		resp.Header.Set("X-Request-Id", "abc")
+		_, err := f.ExtractingData(nil, resp, &Data{})
+		apiErr := &APIError{}
+		assert.True(t, errors.As(err, &apiErr))
+		assert.Equal(t, apiErr, &APIError{StatusCode: 401, Code: "UNAUTHORIZED", Message: "invalid merchant key", RequestID: "abc"})
+	})
+	t.Run("Encrypted error response", func(t *testing.T) {
+		f := Default()
+		pub, _ := os.ReadFile("key.pub")
+		pubKey, _ := utils.BytesToPublicKey(pub)
+		priv, _ := os.ReadFile("key.priv")
+		privKey, _ := utils.BytesToPrivateKey(priv)
+		marshalled, _ := json.Marshal(&ErrorResponse{Code: "DEVICE_NOT_FOUND", Message: "device not found", RequestID: "abc"})
+		encrypted, _ := utils.EncryptWithPublicKey(marshalled, pubKey)
+		message := base64.StdEncoding.EncodeToString(encrypted)
+		resp, _ := httpmock.NewJsonResponse(404, &Transmission{Message: message})
+		_, err := f.ExtractingData(privKey, resp, &Data{})
+		apiErr := &APIError{}
+		assert.True(t, errors.As(err, &apiErr))
+		assert.Equal(t, apiErr, &APIError{StatusCode: 404, Code: "DEVICE_NOT_FOUND", Message: "device not found", RequestID: "abc"})
+	})
+	t.Run("Plain text error response", func(t *testing.T) {
+		f := Default()
+		resp := httpmock.NewStringResponse(502, "<html>bad gateway</html>")
+		_, err := f.ExtractingData(nil, resp, &Data{})
+		apiErr := &APIError{}
+		assert.True(t, errors.As(err, &apiErr))
+		assert.Equal(t, apiErr.Message, "<html>bad gateway</html>")
+	})
+	t.Run("Body not JSON", func(t *testing.T) {
+		f := Default()
+		resp := httpmock.NewStringResponse(200, "KOALA_PANDA")
+		_, err := f.ExtractingData(nil, resp, &Data{})
+		assert.True(t, errors.Is(err, ErrDecrypt))
+	})
+	t.Run("Message not base64", func(t *testing.T) {
+		f := Default()
+		resp, _ := httpmock.NewJsonResponse(200, &Transmission{Message: "KOALA PANDA"})
+		_, err := f.ExtractingData(nil, resp, &Data{})
+		assert.True(t, errors.Is(err, ErrDecrypt))
+	})
+	t.Run("Message encrypted for another key", func(t *testing.T) {
+		f := Default()
+		priv, _ := os.ReadFile("key.priv")
+		privKey, _ := utils.BytesToPrivateKey(priv)
+		message := base64.StdEncoding.EncodeToString([]byte("KOALA_PANDA"))
+		resp, _ := httpmock.NewJsonResponse(200, &Transmission{Message: message})
+		data, err := f.ExtractingData(privKey, resp, &Data{})
+		assert.True(t, errors.Is(err, ErrDecrypt))
+		assert.Equal(t, data, &Data{})
+	})
+}
+
+func TestHybridEncryption(t *testing.T) {
+	pub, _ := os.ReadFile("key.pub")
+	pubKey, _ := utils.BytesToPublicKey(pub)
+	priv, _ := os.ReadFile("key.priv")
+	privKey, _ := utils.BytesToPrivateKey(priv)
+	large := &CheckRequest{Email: "anvarisy@gmail.com", Phone: "085811752000", Data: strings.Repeat("KOALA_PANDA", 100)}
+
+	t.Run("RSA payload too large", func(t *testing.T) {
+		f := Default()
+		_, err := f.WrappingData(pubKey, large)
+		assert.NotEqual(t, err, nil)
+	})
+	t.Run("Hybrid payload round trip", func(t *testing.T) {
+		f := NewFlow(WithEncryptionMode(EncryptionHybrid))
+		wrapped, err := f.WrappingData(pubKey, large)
+		assert.Equal(t, err, nil)
+
+		transmission := &Transmission{}
+		json.Unmarshal(wrapped, transmission)
+		assert.Equal(t, transmission.Mode, EncryptionHybrid)
+		envelope, _ := base64.StdEncoding.DecodeString(transmission.Message)
+		decrypted, err := utils.DecryptEnvelope(envelope, privKey)
+		assert.Equal(t, err, nil)
+		check := &CheckRequest{}
+		json.Unmarshal(decrypted, check)
+		assert.Equal(t, check, large)
+	})
+	t.Run("Hybrid response", func(t *testing.T) {
+		f := Default()
+		marshalled, _ := json.Marshal(&Data{SessionId: strings.Repeat("1", 600)})
+		envelope, _ := utils.EncryptEnvelope(marshalled, pubKey)
+		message := base64.StdEncoding.EncodeToString(envelope)
+		resp, _ := httpmock.NewJsonResponse(200, &Transmission{Message: message, Mode: EncryptionHybrid})
+		data, _ := f.ExtractingData(privKey, resp, &Data{})
+		assert.Equal(t, data.SessionId, strings.Repeat("1", 600))
+	})
 }
@@ -0,0 +1,231 @@
+package fazpass
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/anvarisy/go-fazpass-sdk/utils"
+)
+
+// Keyring holds the merchant private keys able to decrypt responses: the
+// primary key, then the previous ones still accepted during a rotation. It
+// is safe for concurrent use and can be updated while the client runs.
+type Keyring struct {
+	mu        sync.RWMutex
+	entries   []keyringEntry
+	usage     map[string]uint64
+	onDecrypt func(keyId string)
+}
+
+type keyringEntry struct {
+	id  string
+	key crypto.Decrypter
+}
+
+// NewKeyring returns a keyring whose primary key is key. Keys are
+// *rsa.PrivateKey or any RSA crypto.Decrypter, an empty id defaults to the
+// key fingerprint.
+func NewKeyring(id string, key crypto.Decrypter) (*Keyring, error) {
+	k := &Keyring{usage: map[string]uint64{}}
+	if err := k.Rotate(id, key); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// OnDecrypt registers a callback told which key decrypted each response.
+func (k *Keyring) OnDecrypt(callback func(keyId string)) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.onDecrypt = callback
+}
+
+// Rotate makes key the primary key, the current keys are kept as previous keys.
+func (k *Keyring) Rotate(id string, key crypto.Decrypter) error {
+	return k.rotate(id, key, -1)
+}
+
+// rotate is Rotate keeping at most keep previous keys, the oldest being
+// dropped, or all of them when keep is negative.
+func (k *Keyring) rotate(id string, key crypto.Decrypter, keep int) error {
+	entry, err := newKeyringEntry(id, key)
+	if err != nil {
+		return err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	entries := append([]keyringEntry{entry}, k.without(entry.id)...)
+	if keep >= 0 && len(entries) > keep+1 {
+		for _, dropped := range entries[keep+1:] {
+			delete(k.usage, dropped.id)
+		}
+		entries = entries[:keep+1]
+	}
+	k.entries = entries
+	return nil
+}
+
+// Add adds key as the last previous key.
+func (k *Keyring) Add(id string, key crypto.Decrypter) error {
+	entry, err := newKeyringEntry(id, key)
+	if err != nil {
+		return err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if len(k.entries) > 0 && k.entries[0].id == entry.id {
+		return fmt.Errorf("%w: key %s is the primary key", ErrInvalidKey, entry.id)
+	}
+	k.entries = append(k.without(entry.id), entry)
+	return nil
+}
+
+// Remove removes a previous key, the primary key cannot be removed.
+func (k *Keyring) Remove(id string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if len(k.entries) > 0 && k.entries[0].id == id {
+		return fmt.Errorf("%w: key %s is the primary key", ErrInvalidKey, id)
+	}
+	k.entries = k.without(id)
+	return nil
+}
+
+// Primary returns the primary key.
+func (k *Keyring) Primary() crypto.Decrypter {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.entries[0].key
+}
+
+// IDs returns the key ids, primary first.
+func (k *Keyring) IDs() []string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	ids := make([]string, len(k.entries))
+	for i, entry := range k.entries {
+		ids[i] = entry.id
+	}
+	return ids
+}
+
+// Usage returns how many responses each key decrypted.
+func (k *Keyring) Usage() map[string]uint64 {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	usage := make(map[string]uint64, len(k.usage))
+	for id, count := range k.usage {
+		usage[id] = count
+	}
+	return usage
+}
+
+func newKeyringEntry(id string, key crypto.Decrypter) (keyringEntry, error) {
+	if isNil(key) {
+		return keyringEntry{}, fmt.Errorf("%w: key cannot be nil", ErrInvalidKey)
+	}
+	pub, ok := key.Public().(*rsa.PublicKey)
+	if !ok {
+		return keyringEntry{}, fmt.Errorf("%w: key is not an RSA key", ErrInvalidKey)
+	}
+	if id == "" {
+		fingerprint, err := utils.Fingerprint(pub)
+		if err != nil {
+			return keyringEntry{}, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+		}
+		id = fingerprint
+	}
+	return keyringEntry{id: id, key: key}, nil
+}
+
+// without returns the entries except the one identified by id, k.mu must be held.
+func (k *Keyring) without(id string) []keyringEntry {
+	entries := make([]keyringEntry, 0, len(k.entries))
+	for _, entry := range k.entries {
+		if entry.id != id {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func (k *Keyring) used(id string) {
+	k.mu.Lock()
+	k.usage[id]++
+	callback := k.onDecrypt
+	k.mu.Unlock()
+	if callback != nil {
+		callback(id)
+	}
+}
+
+// extract runs flow.ExtractingDataContext with each key in turn until one
+// decrypts the response. An error response is extracted once, with a
+// decrypter trying every key, as its *APIError does not tell whether its
+// envelope was opened. A flow without context steps, only given
+// *rsa.PrivateKey keys, gets the primary key for it instead.
+func (k *Keyring) extract(ctx context.Context, flow FlowContextInterface, response *http.Response, data *Data) (*Data, error) {
+	body, err := io.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return data, ctxErr
+		}
+		return data, fmt.Errorf("%w: %w", ErrTransport, err)
+	}
+	k.mu.RLock()
+	entries := k.entries
+	k.mu.RUnlock()
+	if _, legacy := flow.(contextFlow); !legacy && (response.StatusCode < 200 || response.StatusCode > 299) {
+		attempt := *response
+		attempt.Body = io.NopCloser(bytes.NewReader(body))
+		decrypter := &anyKey{entries: entries}
+		data, err = flow.ExtractingDataContext(ctx, decrypter, &attempt, data)
+		if decrypter.used != "" {
+			k.used(decrypter.used)
+		}
+		return data, err
+	}
+	for _, entry := range entries {
+		attempt := *response
+		attempt.Body = io.NopCloser(bytes.NewReader(body))
+		data, err = flow.ExtractingDataContext(ctx, entry.key, &attempt, data)
+		if err == nil {
+			k.used(entry.id)
+			return data, nil
+		}
+		if !errors.Is(err, ErrDecrypt) {
+			return data, err
+		}
+	}
+	return data, err
+}
+
+// anyKey decrypts with the first of entries able to, remembering its id.
+type anyKey struct {
+	entries []keyringEntry
+	used    string
+}
+
+func (a *anyKey) Public() crypto.PublicKey {
+	return a.entries[0].key.Public()
+}
+
+func (a *anyKey) Decrypt(rand io.Reader, ciphertext []byte, opts crypto.DecrypterOpts) ([]byte, error) {
+	var err error
+	for _, entry := range a.entries {
+		var plaintext []byte
+		if plaintext, err = entry.key.Decrypt(rand, ciphertext, opts); err == nil {
+			a.used = entry.id
+			return plaintext, nil
+		}
+	}
+	return nil, err
+}
@@ -0,0 +1,84 @@
+package fazpass
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/anvarisy/go-fazpass-sdk/utils"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyring(t *testing.T) {
+	pub, _ := os.ReadFile("key.pub")
+	priv, _ := os.ReadFile("key.priv")
+	oldKey, _ := utils.BytesToPrivateKey(priv)
+	newKey, _ := utils.GenerateKeyPair(2048)
+
+	setup := func(t *testing.T, keyring *Keyring) *Fazpass {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+		marshalled, _ := json.Marshal(&Data{SessionId: "1"})
+		encrypted, _ := utils.EncryptWithPublicKey(marshalled, &oldKey.PublicKey)
+		httpmock.RegisterResponder("POST", "http://localhost/check",
+			httpmock.NewJsonResponderOrPanic(200, &Transmission{Message: base64.StdEncoding.EncodeToString(encrypted)}))
+		f, err := New(WithKeyring(keyring), WithPublicKeyPEM(pub), WithMerchantKey("MERCHANT_KEY"), WithBaseURL("http://localhost"))
+		assert.Equal(t, err, nil)
+		return f
+	}
+
+	t.Run("Previous key decrypts", func(t *testing.T) {
+		keyring, _ := NewKeyring("old", oldKey)
+		keyring.Rotate("new", newKey)
+		used := []string{}
+		keyring.OnDecrypt(func(keyId string) { used = append(used, keyId) })
+		f := setup(t, keyring)
+
+		data, err := f.Check("anvarisy@gmail.com", "085811752000", "KOALA_PANDA")
+		assert.Equal(t, err, nil)
+		assert.Equal(t, data.SessionId, "1")
+		assert.Equal(t, used, []string{"old"})
+		assert.Equal(t, keyring.Usage(), map[string]uint64{"old": 1})
+		assert.Equal(t, keyring.IDs(), []string{"new", "old"})
+	})
+	t.Run("Error encrypted for a previous key", func(t *testing.T) {
+		keyring, _ := NewKeyring("old", oldKey)
+		keyring.Rotate("new", newKey)
+		f := setup(t, keyring)
+		marshalled, _ := json.Marshal(&ErrorResponse{Code: "DEVICE_NOT_FOUND", Message: "device not found"})
+		encrypted, _ := utils.EncryptWithPublicKey(marshalled, &oldKey.PublicKey)
+		httpmock.RegisterResponder("POST", "http://localhost/check",
+			httpmock.NewJsonResponderOrPanic(404, &Transmission{Message: base64.StdEncoding.EncodeToString(encrypted)}))
+
+		_, err := f.Check("anvarisy@gmail.com", "085811752000", "KOALA_PANDA")
+		apiErr := &APIError{}
+		assert.True(t, errors.As(err, &apiErr))
+		assert.Equal(t, apiErr.Code, "DEVICE_NOT_FOUND")
+		assert.Equal(t, apiErr.Message, "device not found")
+		assert.Equal(t, keyring.Usage(), map[string]uint64{"old": 1})
+	})
+	t.Run("Removed key", func(t *testing.T) {
+		keyring, _ := NewKeyring("old", oldKey)
+		keyring.Rotate("new", newKey)
+		assert.Equal(t, keyring.Remove("old"), nil)
+		f := setup(t, keyring)
+
+		_, err := f.Check("anvarisy@gmail.com", "085811752000", "KOALA_PANDA")
+		assert.True(t, errors.Is(err, ErrDecrypt))
+	})
+	t.Run("Primary key cannot be removed", func(t *testing.T) {
+		keyring, _ := NewKeyring("", newKey)
+		err := keyring.Remove(keyring.IDs()[0])
+		assert.True(t, errors.Is(err, ErrInvalidKey))
+		assert.Equal(t, keyring.Primary(), newKey)
+	})
+	t.Run("Add previous key", func(t *testing.T) {
+		keyring, _ := NewKeyring("new", newKey)
+		assert.Equal(t, keyring.Add("old", oldKey), nil)
+		assert.Equal(t, keyring.IDs(), []string{"new", "old"})
+		assert.True(t, errors.Is(keyring.Add("new", oldKey), ErrInvalidKey))
+	})
+}
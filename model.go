@@ -31,8 +31,28 @@ type Device struct {
 	Timezone        string      `json:"timezone"`
 }
 
+// EncryptionMode tells how a Transmission message is encrypted.
+type EncryptionMode string
+
+const (
+	// EncryptionRSA encrypts the payload directly with RSA PKCS#1 v1.5, which
+	// limits it to the key size minus 11 bytes.
+	EncryptionRSA EncryptionMode = "rsa"
+	// EncryptionHybrid encrypts the payload with AES-256-GCM under a random key
+	// wrapped with RSA-OAEP, so the payload size is unbounded.
+	EncryptionHybrid EncryptionMode = "hybrid"
+)
+
 type Transmission struct {
-	Message string `json:"message"`
+	Message string         `json:"message"`
+	Mode    EncryptionMode `json:"mode,omitempty"`
+}
+
+// ErrorResponse is the body Fazpass sends along a non-2xx status.
+type ErrorResponse struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
 }
 
 type CheckRequest struct {
@@ -0,0 +1,187 @@
+package fazpass
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/anvarisy/go-fazpass-sdk/utils"
+)
+
+// Option configures a Fazpass client built by New.
+type Option func(f *Fazpass) error
+
+// New builds a Fazpass client from the given options. Unlike Initialize it
+// reports unreadable keys and missing settings instead of deferring the
+// failure to the first request.
+func New(opts ...Option) (*Fazpass, error) {
+	f := &Fazpass{}
+	for _, opt := range opts {
+		if err := opt(f); err != nil {
+			return nil, err
+		}
+	}
+	if f.Flow == nil {
+		f.Flow = NewFlow(f.flowOptions...)
+	} else if len(f.flowOptions) > 0 {
+		return nil, errors.New("flow options cannot be combined with a custom flow")
+	}
+	if err := f.validate(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *Fazpass) validate() error {
+	if f.PrivateKey == nil && f.Decrypter == nil && f.Keyring == nil {
+		return fmt.Errorf("%w: private key is required", ErrInvalidKey)
+	}
+	if f.PublicKey == nil {
+		return fmt.Errorf("%w: public key is required", ErrInvalidKey)
+	}
+	if f.MerchantKey == "" && f.Credentials == nil {
+		return errors.New("merchant key is required")
+	}
+	if f.BaseUrl == "" {
+		return errors.New("base url is required")
+	}
+	u, err := url.Parse(f.BaseUrl)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return errors.New("base url is invalid")
+	}
+	return nil
+}
+
+// WithPrivateKeyPEM sets the merchant private key, given as PEM (PKCS#1 or
+// PKCS#8) or as a JWK.
+func WithPrivateKeyPEM(priv []byte) Option {
+	return WithEncryptedPrivateKeyPEM(priv, nil)
+}
+
+// WithEncryptedPrivateKeyPEM is like WithPrivateKeyPEM for a password
+// protected key.
+func WithEncryptedPrivateKeyPEM(priv []byte, password []byte) Option {
+	return func(f *Fazpass) error {
+		key, err := utils.ParsePrivateKey(priv, password)
+		if err != nil {
+			return fmt.Errorf("%w: private key: %v", ErrInvalidKey, err)
+		}
+		f.PrivateKey = key
+		return nil
+	}
+}
+
+// WithPublicKeyPEM sets the Fazpass public key, given as PEM (PKIX, PKCS#1 or
+// a certificate) or as a JWK.
+func WithPublicKeyPEM(pub []byte) Option {
+	return func(f *Fazpass) error {
+		key, err := utils.ParsePublicKey(pub)
+		if err != nil {
+			return fmt.Errorf("%w: public key: %v", ErrInvalidKey, err)
+		}
+		f.PublicKey = key
+		return nil
+	}
+}
+
+// WithKeys sets already parsed keys.
+func WithKeys(privKey *rsa.PrivateKey, pubKey *rsa.PublicKey) Option {
+	return func(f *Fazpass) error {
+		if privKey == nil || pubKey == nil {
+			return fmt.Errorf("%w: keys cannot be nil", ErrInvalidKey)
+		}
+		f.PrivateKey = privKey
+		f.PublicKey = pubKey
+		return nil
+	}
+}
+
+// WithDecrypter decrypts responses with decrypter, e.g. a KMS or HSM backed
+// key, instead of a private key.
+func WithDecrypter(decrypter crypto.Decrypter) Option {
+	return func(f *Fazpass) error {
+		if isNil(decrypter) {
+			return fmt.Errorf("%w: decrypter cannot be nil", ErrInvalidKey)
+		}
+		if _, ok := decrypter.Public().(*rsa.PublicKey); !ok {
+			return fmt.Errorf("%w: decrypter is not an RSA key", ErrInvalidKey)
+		}
+		f.Decrypter = decrypter
+		return nil
+	}
+}
+
+// WithKeyring decrypts responses with any key of keyring, for key rotation.
+// No private key is needed besides it.
+func WithKeyring(keyring *Keyring) Option {
+	return func(f *Fazpass) error {
+		if keyring == nil {
+			return fmt.Errorf("%w: keyring cannot be nil", ErrInvalidKey)
+		}
+		f.Keyring = keyring
+		return nil
+	}
+}
+
+// WithMerchantKey sets the merchant key sent as bearer token.
+func WithMerchantKey(merchantKey string) Option {
+	return func(f *Fazpass) error {
+		f.MerchantKey = merchantKey
+		return nil
+	}
+}
+
+// WithCredentialProvider fetches the merchant key from provider on every
+// request instead of using a fixed one.
+func WithCredentialProvider(provider CredentialProvider) Option {
+	return func(f *Fazpass) error {
+		if provider == nil {
+			return errors.New("credential provider cannot be nil")
+		}
+		f.Credentials = provider
+		return nil
+	}
+}
+
+// WithBaseURL sets the Fazpass API base url, e.g. https://api.fazpass.com/v1.
+func WithBaseURL(baseUrl string) Option {
+	return func(f *Fazpass) error {
+		f.BaseUrl = strings.TrimRight(baseUrl, "/")
+		return nil
+	}
+}
+
+// WithFlow replaces the default flow, mostly useful for tests. The flow gets
+// the context of each call when it implements FlowContextInterface.
+func WithFlow(flow FlowInterface) Option {
+	return func(f *Fazpass) error {
+		if flow == nil {
+			return errors.New("flow cannot be nil")
+		}
+		f.Flow = flow
+		return nil
+	}
+}
+
+// WithFlowOptions configures the default flow, e.g. to select its encryption mode.
+func WithFlowOptions(opts ...FlowOption) Option {
+	return func(f *Fazpass) error {
+		f.flowOptions = append(f.flowOptions, opts...)
+		return nil
+	}
+}
+
+// WithHTTPClient makes the default flow send requests through client.
+func WithHTTPClient(client *http.Client) Option {
+	return func(f *Fazpass) error {
+		if client == nil {
+			return errors.New("http client cannot be nil")
+		}
+		f.flowOptions = append(f.flowOptions, withClient(client))
+		return nil
+	}
+}
@@ -0,0 +1,80 @@
+package fazpass
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/anvarisy/go-fazpass-sdk/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	priv, _ := os.ReadFile("key.priv")
+	pub, _ := os.ReadFile("key.pub")
+
+	t.Run("Keys from PEM", func(t *testing.T) {
+		f, err := New(
+			WithPrivateKeyPEM(priv),
+			WithPublicKeyPEM(pub),
+			WithMerchantKey("MERCHANT_KEY"),
+			WithBaseURL("http://localhost:8080/"),
+		)
+		assert.Equal(t, err, nil)
+		assert.NotNil(t, f.PrivateKey)
+		assert.NotNil(t, f.PublicKey)
+		assert.Equal(t, f.BaseUrl, "http://localhost:8080")
+	})
+	t.Run("Parsed keys", func(t *testing.T) {
+		privKey, _ := utils.BytesToPrivateKey(priv)
+		pubKey, _ := utils.BytesToPublicKey(pub)
+		f, err := New(WithKeys(privKey, pubKey), WithMerchantKey("MERCHANT_KEY"), WithBaseURL("http://localhost:8080"))
+		assert.Equal(t, err, nil)
+		assert.Equal(t, f.PrivateKey, privKey)
+	})
+	t.Run("Private key not PEM", func(t *testing.T) {
+		_, err := New(WithPrivateKeyPEM([]byte("KOALA_PANDA")))
+		assert.True(t, errors.Is(err, ErrInvalidKey))
+	})
+	t.Run("Public key not PEM", func(t *testing.T) {
+		_, err := New(WithPublicKeyPEM(nil))
+		assert.True(t, errors.Is(err, ErrInvalidKey))
+	})
+	t.Run("Encrypted private key", func(t *testing.T) {
+		encrypted, _ := os.ReadFile("utils/testdata/pkcs8_encrypted.pem")
+		_, err := New(WithEncryptedPrivateKeyPEM(encrypted, []byte("fazpass")))
+		assert.Equal(t, err.Error(), "invalid key: public key is required")
+		_, err = New(WithEncryptedPrivateKeyPEM(encrypted, []byte("KOALA_PANDA")))
+		assert.Equal(t, err.Error(), "invalid key: private key: cannot decrypt private key: wrong password")
+	})
+	t.Run("Private key too small", func(t *testing.T) {
+		small, _ := os.ReadFile("utils/testdata/small.pem")
+		_, err := New(WithPrivateKeyPEM(small))
+		assert.True(t, errors.Is(err, ErrInvalidKey))
+	})
+	t.Run("Private key missing", func(t *testing.T) {
+		_, err := New(WithPublicKeyPEM(pub), WithMerchantKey("MERCHANT_KEY"), WithBaseURL("http://localhost:8080"))
+		assert.True(t, errors.Is(err, ErrInvalidKey))
+	})
+	t.Run("Merchant key missing", func(t *testing.T) {
+		_, err := New(WithPrivateKeyPEM(priv), WithPublicKeyPEM(pub), WithBaseURL("http://localhost:8080"))
+		assert.Equal(t, err.Error(), "merchant key is required")
+	})
+	t.Run("Base url invalid", func(t *testing.T) {
+		_, err := New(WithPrivateKeyPEM(priv), WithPublicKeyPEM(pub), WithMerchantKey("MERCHANT_KEY"), WithBaseURL("localhost"))
+		assert.Equal(t, err.Error(), "base url is invalid")
+	})
+	t.Run("HTTP client", func(t *testing.T) {
+		client := &http.Client{}
+		f, err := New(WithPrivateKeyPEM(priv), WithPublicKeyPEM(pub), WithMerchantKey("MERCHANT_KEY"),
+			WithBaseURL("http://localhost:8080"), WithHTTPClient(client))
+		assert.Equal(t, err, nil)
+		assert.Equal(t, f.Flow.(*Flow).client, client)
+	})
+	t.Run("HTTP client with custom flow", func(t *testing.T) {
+		_, err := New(WithPrivateKeyPEM(priv), WithPublicKeyPEM(pub), WithMerchantKey("MERCHANT_KEY"),
+			WithBaseURL("http://localhost:8080"), WithFlow(new(FlowMock)), WithHTTPClient(&http.Client{}))
+		assert.Equal(t, err.Error(), "flow options cannot be combined with a custom flow")
+	})
+}
@@ -0,0 +1,103 @@
+package policy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	fazpass "github.com/anvarisy/go-fazpass-sdk"
+)
+
+// maxRecordSize bounds the length of a recorded line.
+const maxRecordSize = 1 << 20
+
+// Change is a decision changed from the baseline to the candidate policy.
+type Change struct {
+	From Decision `json:"from"`
+	To   Decision `json:"to"`
+}
+
+// ReasonCount is how many records a reason code was reported for.
+type ReasonCount struct {
+	Reason string `json:"reason"`
+	Count  int    `json:"count"`
+}
+
+// Report summarizes a backtest.
+type Report struct {
+	Records int `json:"records"`
+	// Decisions counts the decisions of the candidate policy.
+	Decisions map[Decision]int `json:"decisions"`
+	// BaselineDecisions counts the decisions of the baseline policy.
+	BaselineDecisions map[Decision]int `json:"baseline_decisions"`
+	// Changed counts the records decided otherwise than by the baseline.
+	Changed int `json:"changed"`
+	// Changes counts the changed records by change.
+	Changes map[Change]int `json:"-"`
+	// Reasons counts the reason codes reported by the candidate policy.
+	Reasons map[string]int `json:"reasons"`
+}
+
+// Backtest evaluates the Data recorded as JSON lines in r with candidate and
+// baseline, e.g. the enforced policy, and reports how the decisions differ.
+// Blank lines are skipped.
+func Backtest(r io.Reader, candidate fazpass.Evaluator, baseline fazpass.Evaluator) (*Report, error) {
+	report := &Report{
+		Decisions:         map[Decision]int{},
+		BaselineDecisions: map[Decision]int{},
+		Changes:           map[Change]int{},
+		Reasons:           map[string]int{},
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxRecordSize)
+	for line := 1; scanner.Scan(); line++ {
+		record := bytes.TrimSpace(scanner.Bytes())
+		if len(record) == 0 {
+			continue
+		}
+		data := &fazpass.Data{}
+		if err := json.Unmarshal(record, data); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		report.add(candidate.Evaluate(data), baseline.Evaluate(data))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func (report *Report) add(verdict Verdict, baseline Verdict) {
+	report.Records++
+	report.Decisions[verdict.Decision]++
+	report.BaselineDecisions[baseline.Decision]++
+	if verdict.Decision != baseline.Decision {
+		report.Changed++
+		report.Changes[Change{From: baseline.Decision, To: verdict.Decision}]++
+	}
+	for _, reason := range verdict.Reasons {
+		report.Reasons[reason]++
+	}
+}
+
+// TopReasons returns the n most reported reason codes, all of them when n is
+// not positive.
+func (report *Report) TopReasons(n int) []ReasonCount {
+	reasons := make([]ReasonCount, 0, len(report.Reasons))
+	for reason, count := range report.Reasons {
+		reasons = append(reasons, ReasonCount{Reason: reason, Count: count})
+	}
+	sort.Slice(reasons, func(i, j int) bool {
+		if reasons[i].Count != reasons[j].Count {
+			return reasons[i].Count > reasons[j].Count
+		}
+		return reasons[i].Reason < reasons[j].Reason
+	})
+	if n > 0 && len(reasons) > n {
+		reasons = reasons[:n]
+	}
+	return reasons
+}
@@ -0,0 +1,33 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const records = `{"session_id":"1","device":{"score":1}}
+{"session_id":"2","device":{"score":0.9,"is_vpn":true}}
+
+{"session_id":"3","device":{"score":0.3,"is_rooted":true,"is_vpn":true}}
+`
+
+func TestBacktest(t *testing.T) {
+	candidate := New(When(Deny, ReasonVpn, Vpn), When(Challenge, ReasonLowScore, ScoreBelow(0.5)))
+	t.Run("Report", func(t *testing.T) {
+		report, err := Backtest(strings.NewReader(records), candidate, Default())
+		assert.Equal(t, err, nil)
+		assert.Equal(t, report.Records, 3)
+		assert.Equal(t, report.Decisions, map[Decision]int{Allow: 1, Deny: 2})
+		assert.Equal(t, report.BaselineDecisions, map[Decision]int{Allow: 1, Challenge: 1, Deny: 1})
+		assert.Equal(t, report.Changed, 1)
+		assert.Equal(t, report.Changes, map[Change]int{{From: Challenge, To: Deny}: 1})
+		assert.Equal(t, report.TopReasons(0), []ReasonCount{{ReasonVpn, 2}, {ReasonLowScore, 1}})
+		assert.Equal(t, report.TopReasons(1), []ReasonCount{{ReasonVpn, 2}})
+	})
+	t.Run("Invalid record", func(t *testing.T) {
+		_, err := Backtest(strings.NewReader(records+"{\"device\":\n"), candidate, Default())
+		assert.Equal(t, err.Error(), "line 5: unexpected end of JSON input")
+	})
+}
@@ -0,0 +1,107 @@
+package policy
+
+import (
+	"math"
+
+	fazpass "github.com/anvarisy/go-fazpass-sdk"
+)
+
+// Conditions over the device signals.
+var (
+	Rooted        Condition = func(device *fazpass.Device) bool { return device.IsRooted }
+	Emulator      Condition = func(device *fazpass.Device) bool { return device.IsEmulator }
+	GpsSpoof      Condition = func(device *fazpass.Device) bool { return device.IsGpsSpoof }
+	AppTemper     Condition = func(device *fazpass.Device) bool { return device.IsAppTemper }
+	Vpn           Condition = func(device *fazpass.Device) bool { return device.IsVpn }
+	ScreenSharing Condition = func(device *fazpass.Device) bool { return device.IsScreenSharing }
+	Debugging     Condition = func(device *fazpass.Device) bool { return device.IsDebuging }
+)
+
+// ScoreBelow holds when the device score is lower than threshold.
+func ScoreBelow(threshold float64) Condition {
+	return func(device *fazpass.Device) bool {
+		return device.Score < threshold
+	}
+}
+
+// Platform holds when the device runs on one of platforms.
+func Platform(platforms ...string) Condition {
+	return func(device *fazpass.Device) bool {
+		for _, platform := range platforms {
+			if device.Platform == platform {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// All holds when every condition holds.
+func All(conditions ...Condition) Condition {
+	return func(device *fazpass.Device) bool {
+		for _, condition := range conditions {
+			if !condition(device) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Any holds when at least one condition holds.
+func Any(conditions ...Condition) Condition {
+	return func(device *fazpass.Device) bool {
+		for _, condition := range conditions {
+			if condition(device) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not holds when condition does not.
+func Not(condition Condition) Condition {
+	return func(device *fazpass.Device) bool {
+		return !condition(device)
+	}
+}
+
+// ScoreAtLeast holds when the device score is threshold or higher.
+func ScoreAtLeast(threshold float64) Condition {
+	return func(device *fazpass.Device) bool {
+		return device.Score >= threshold
+	}
+}
+
+// Timezone holds when the device is set to one of timezones.
+func Timezone(timezones ...string) Condition {
+	return func(device *fazpass.Device) bool {
+		for _, timezone := range timezones {
+			if device.Timezone == timezone {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// earthRadiusKm is the mean radius of the Earth.
+const earthRadiusKm = 6371.0
+
+// Near holds when the device is located within radiusKm kilometers of the
+// given coordinates.
+func Near(latitude float64, longitude float64, radiusKm float64) Condition {
+	return func(device *fazpass.Device) bool {
+		return distanceKm(latitude, longitude, device.Geolocation.Latitude, device.Geolocation.Longitude) <= radiusKm
+	}
+}
+
+// distanceKm is the great-circle distance between two coordinates.
+func distanceKm(lat1 float64, lon1 float64, lat2 float64, lon2 float64) float64 {
+	toRadians := func(degrees float64) float64 { return degrees * math.Pi / 180 }
+	dLat := toRadians(lat2 - lat1)
+	dLon := toRadians(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
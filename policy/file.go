@@ -0,0 +1,409 @@
+package policy
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	fazpass "github.com/anvarisy/go-fazpass-sdk"
+	"gopkg.in/yaml.v3"
+)
+
+// Error reports an invalid policy file, Line is zero when unknown.
+type Error struct {
+	Path string
+	Line int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	location := e.Path
+	if e.Line > 0 {
+		if location != "" {
+			location += ":"
+		}
+		location += "line " + strconv.Itoa(e.Line)
+	}
+	if location == "" {
+		return "invalid policy: " + e.Msg
+	}
+	return fmt.Sprintf("invalid policy %s: %s", location, e.Msg)
+}
+
+// LoadFile loads a policy from a YAML or JSON file, see Parse.
+func LoadFile(path string) (*Policy, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p, err := Parse(content)
+	if policyErr, ok := err.(*Error); ok {
+		policyErr.Path = path
+	}
+	return p, err
+}
+
+// Parse reads a policy written in YAML or JSON:
+//
+//	version: 1
+//	default: allow
+//	rules:
+//	  - reason: rooted
+//	    decision: deny
+//	    when: {rooted: true}
+//	  - reason: risky_android
+//	    decision: challenge
+//	    when:
+//	      platform: [android]
+//	      score: {min: 0.2, max: 0.5}
+//	      not:
+//	        geolocation: {latitude: -6.2, longitude: 106.8, radius_km: 50}
+//
+// A rule applies when every entry of its when block holds. The entries are
+// the device flags rooted, emulator, gps_spoof, app_temper, vpn,
+// screen_sharing and debugging, score with an inclusive min and an exclusive
+// max, platform and timezone lists, geolocation, and the any, all and not
+// combinators. Errors are reported as *Error with the offending line.
+func Parse(content []byte) (*Policy, error) {
+	document := &yaml.Node{}
+	if err := yaml.Unmarshal(content, document); err != nil {
+		return nil, syntaxError(err)
+	}
+	if len(document.Content) == 0 {
+		return nil, &Error{Line: 1, Msg: "empty policy"}
+	}
+	root := resolve(document.Content[0])
+	if err := expect(root, yaml.MappingNode, "a mapping"); err != nil {
+		return nil, err
+	}
+
+	p := New()
+	versionFound := false
+	err := fields(root, func(key string, value *yaml.Node) error {
+		switch key {
+		case "version":
+			versionFound = true
+			if err := value.Decode(&p.Version); err != nil || p.Version < 1 {
+				return errorf(value, "version must be a positive integer")
+			}
+		case "default":
+			decision, err := decision(value)
+			if err != nil {
+				return err
+			}
+			p.Default = decision
+		case "rules":
+			if err := expect(value, yaml.SequenceNode, "a list of rules"); err != nil {
+				return err
+			}
+			for _, node := range value.Content {
+				rule, err := parseRule(resolve(node))
+				if err != nil {
+					return err
+				}
+				p.Rules = append(p.Rules, rule)
+			}
+		default:
+			return errorf(value, "unknown field %q", key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !versionFound {
+		return nil, errorf(root, "missing version")
+	}
+	return p, nil
+}
+
+func parseRule(node *yaml.Node) (Rule, error) {
+	rule := Rule{}
+	if err := expect(node, yaml.MappingNode, "a rule"); err != nil {
+		return rule, err
+	}
+	decisionFound := false
+	err := fields(node, func(key string, value *yaml.Node) error {
+		var err error
+		switch key {
+		case "reason":
+			rule.Reason, err = str(value, key)
+		case "decision":
+			decisionFound = true
+			rule.Decision, err = decision(value)
+		case "when":
+			rule.When, err = parseCondition(value)
+		default:
+			err = errorf(value, "unknown field %q", key)
+		}
+		return err
+	})
+	if err != nil {
+		return rule, err
+	}
+	switch {
+	case rule.Reason == "":
+		return rule, errorf(node, "rule without reason")
+	case !decisionFound:
+		return rule, errorf(node, "rule %q without decision", rule.Reason)
+	case rule.When == nil:
+		return rule, errorf(node, "rule %q without when", rule.Reason)
+	}
+	return rule, nil
+}
+
+// flags maps the flag names of a when block to their condition.
+var flags = map[string]Condition{
+	"rooted":         Rooted,
+	"emulator":       Emulator,
+	"gps_spoof":      GpsSpoof,
+	"app_temper":     AppTemper,
+	"vpn":            Vpn,
+	"screen_sharing": ScreenSharing,
+	"debugging":      Debugging,
+}
+
+func parseCondition(node *yaml.Node) (Condition, error) {
+	node = resolve(node)
+	if err := expect(node, yaml.MappingNode, "a mapping of conditions"); err != nil {
+		return nil, err
+	}
+	if len(node.Content) == 0 {
+		return nil, errorf(node, "empty condition")
+	}
+	var conditions []Condition
+	err := fields(node, func(key string, value *yaml.Node) error {
+		condition, err := parseEntry(key, value)
+		if err != nil {
+			return err
+		}
+		conditions = append(conditions, condition)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(conditions) == 1 {
+		return conditions[0], nil
+	}
+	return All(conditions...), nil
+}
+
+func parseEntry(key string, value *yaml.Node) (Condition, error) {
+	if flag, ok := flags[key]; ok {
+		var expected bool
+		if value.Kind != yaml.ScalarNode || value.Decode(&expected) != nil {
+			return nil, errorf(value, "%s must be true or false", key)
+		}
+		if !expected {
+			return Not(flag), nil
+		}
+		return flag, nil
+	}
+	switch key {
+	case "score":
+		return parseScore(value)
+	case "platform":
+		platforms, err := strs(value, key)
+		return Platform(platforms...), err
+	case "timezone":
+		timezones, err := strs(value, key)
+		return Timezone(timezones...), err
+	case "geolocation":
+		return parseGeolocation(value)
+	case "not":
+		condition, err := parseCondition(value)
+		if err != nil {
+			return nil, err
+		}
+		return Not(condition), nil
+	case "any", "all":
+		if err := expect(value, yaml.SequenceNode, "a list of conditions"); err != nil {
+			return nil, err
+		}
+		if len(value.Content) == 0 {
+			return nil, errorf(value, "%s without conditions", key)
+		}
+		conditions := make([]Condition, 0, len(value.Content))
+		for _, node := range value.Content {
+			condition, err := parseCondition(node)
+			if err != nil {
+				return nil, err
+			}
+			conditions = append(conditions, condition)
+		}
+		if key == "any" {
+			return Any(conditions...), nil
+		}
+		return All(conditions...), nil
+	default:
+		return nil, errorf(value, "unknown condition %q", key)
+	}
+}
+
+func parseScore(node *yaml.Node) (Condition, error) {
+	if err := expect(node, yaml.MappingNode, "a mapping with min and max"); err != nil {
+		return nil, err
+	}
+	var conditions []Condition
+	var low, high *float64
+	err := fields(node, func(key string, value *yaml.Node) error {
+		score, err := number(value, "score "+key)
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "min":
+			low = &score
+			conditions = append(conditions, ScoreAtLeast(score))
+		case "max":
+			high = &score
+			conditions = append(conditions, ScoreBelow(score))
+		default:
+			return errorf(value, "unknown field %q", key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if low == nil && high == nil {
+		return nil, errorf(node, "score needs min or max")
+	}
+	if low != nil && high != nil && *low >= *high {
+		return nil, errorf(node, "score min must be lower than max")
+	}
+	return All(conditions...), nil
+}
+
+func parseGeolocation(node *yaml.Node) (Condition, error) {
+	if err := expect(node, yaml.MappingNode, "a mapping with latitude, longitude and radius_km"); err != nil {
+		return nil, err
+	}
+	values := map[string]float64{}
+	err := fields(node, func(key string, value *yaml.Node) error {
+		switch key {
+		case "latitude", "longitude", "radius_km":
+		default:
+			return errorf(value, "unknown field %q", key)
+		}
+		number, err := number(value, key)
+		values[key] = number
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range []string{"latitude", "longitude", "radius_km"} {
+		if _, ok := values[key]; !ok {
+			return nil, errorf(node, "geolocation without %s", key)
+		}
+	}
+	switch {
+	case values["latitude"] < -90 || values["latitude"] > 90:
+		return nil, errorf(node, "latitude must be between -90 and 90")
+	case values["longitude"] < -180 || values["longitude"] > 180:
+		return nil, errorf(node, "longitude must be between -180 and 180")
+	case values["radius_km"] <= 0:
+		return nil, errorf(node, "radius_km must be positive")
+	}
+	return Near(values["latitude"], values["longitude"], values["radius_km"]), nil
+}
+
+// fields calls fn with each key and value of a mapping, rejecting duplicates.
+func fields(node *yaml.Node, fn func(key string, value *yaml.Node) error) error {
+	seen := map[string]bool{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i]
+		if seen[key.Value] {
+			return errorf(key, "duplicate field %q", key.Value)
+		}
+		seen[key.Value] = true
+		if err := fn(key.Value, resolve(node.Content[i+1])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decision(node *yaml.Node) (Decision, error) {
+	if node.Kind != yaml.ScalarNode {
+		return Allow, errorf(node, "decision must be allow, challenge or deny")
+	}
+	decision, err := fazpass.ParseDecision(node.Value)
+	if err != nil {
+		return Allow, errorf(node, "%v, expected allow, challenge or deny", err)
+	}
+	return decision, nil
+}
+
+func str(node *yaml.Node, name string) (string, error) {
+	if node.Kind != yaml.ScalarNode || node.Value == "" {
+		return "", errorf(node, "%s must be a non-empty string", name)
+	}
+	return node.Value, nil
+}
+
+func strs(node *yaml.Node, name string) ([]string, error) {
+	if node.Kind == yaml.ScalarNode {
+		value, err := str(node, name)
+		return []string{value}, err
+	}
+	if err := expect(node, yaml.SequenceNode, "a string or a list of strings"); err != nil {
+		return nil, err
+	}
+	values := make([]string, 0, len(node.Content))
+	for _, item := range node.Content {
+		value, err := str(resolve(item), name)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	if len(values) == 0 {
+		return nil, errorf(node, "%s must not be empty", name)
+	}
+	return values, nil
+}
+
+func number(node *yaml.Node, name string) (float64, error) {
+	var value float64
+	if node.Kind != yaml.ScalarNode || node.Decode(&value) != nil {
+		return 0, errorf(node, "%s must be a number", name)
+	}
+	return value, nil
+}
+
+func expect(node *yaml.Node, kind yaml.Kind, what string) error {
+	if node.Kind != kind {
+		return errorf(node, "expected %s", what)
+	}
+	return nil
+}
+
+// resolve follows YAML aliases.
+func resolve(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
+
+func errorf(node *yaml.Node, format string, args ...interface{}) error {
+	return &Error{Line: node.Line, Msg: fmt.Sprintf(format, args...)}
+}
+
+var syntaxLine = regexp.MustCompile(`^yaml: line (\d+): `)
+
+// syntaxError turns a YAML syntax error into an *Error.
+func syntaxError(err error) error {
+	msg := err.Error()
+	policyErr := &Error{Msg: strings.TrimPrefix(msg, "yaml: ")}
+	if match := syntaxLine.FindStringSubmatch(msg); match != nil {
+		policyErr.Line, _ = strconv.Atoi(match[1])
+		policyErr.Msg = msg[len(match[0]):]
+	}
+	return policyErr
+}
@@ -0,0 +1,70 @@
+package policy
+
+import (
+	"errors"
+	"testing"
+
+	fazpass "github.com/anvarisy/go-fazpass-sdk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadFile(t *testing.T) {
+	jakarta := fazpass.Geolocation{Latitude: -6.17, Longitude: 106.82}
+	t.Run("YAML", func(t *testing.T) {
+		p, err := LoadFile("testdata/policy.yaml")
+		assert.Equal(t, err, nil)
+		assert.Equal(t, p.Version, 2)
+		assert.Equal(t, p.Evaluate(&fazpass.Data{Device: fazpass.Device{Score: 1, Platform: "android", Geolocation: jakarta}}), Verdict{Decision: Allow})
+		verdict := p.Evaluate(&fazpass.Data{Device: fazpass.Device{Score: 0.3, IsRooted: true, Platform: "android", Geolocation: fazpass.Geolocation{Latitude: 51.5, Longitude: -0.1}}})
+		assert.Equal(t, verdict, Verdict{Decision: Deny, Reasons: []string{"rooted", "low_score", "abroad"}})
+		verdict = p.Evaluate(&fazpass.Data{Device: fazpass.Device{Score: 1, Platform: "ios", Timezone: "Europe/London", Geolocation: jakarta}})
+		assert.Equal(t, verdict, Verdict{Decision: Challenge, Reasons: []string{"abroad"}})
+	})
+	t.Run("JSON", func(t *testing.T) {
+		p, err := LoadFile("testdata/policy.json")
+		assert.Equal(t, err, nil)
+		assert.Equal(t, p.Version, 1)
+		assert.Equal(t, p.Evaluate(&fazpass.Data{Device: fazpass.Device{Score: 0.9}}).Decision, Allow)
+		assert.Equal(t, p.Evaluate(&fazpass.Data{Device: fazpass.Device{Score: 0.9, IsVpn: true}}).Decision, Challenge)
+		assert.Equal(t, p.Evaluate(&fazpass.Data{Device: fazpass.Device{Score: 0.5}}).Decision, Challenge)
+	})
+	t.Run("File not found", func(t *testing.T) {
+		_, err := LoadFile("testdata/missing.yaml")
+		assert.NotEqual(t, err, nil)
+	})
+}
+
+func TestParse(t *testing.T) {
+	invalid := []struct {
+		name    string
+		content string
+		line    int
+		msg     string
+	}{
+		{"Empty", "", 1, "empty policy"},
+		{"Syntax", "version: 1\nrules: [\n", 2, "did not find expected node content"},
+		{"Missing version", "default: deny\n", 1, "missing version"},
+		{"Negative version", "version: -1\n", 1, "version must be a positive integer"},
+		{"Unknown field", "version: 1\nname: strict\n", 2, `unknown field "name"`},
+		{"Unknown decision", "version: 1\nrules:\n  - reason: vpn\n    decision: block\n    when: {vpn: true}\n", 4, `unknown decision "block", expected allow, challenge or deny`},
+		{"Missing decision", "version: 1\nrules:\n  - reason: vpn\n    when: {vpn: true}\n", 3, `rule "vpn" without decision`},
+		{"Unknown condition", "version: 1\nrules:\n  - reason: vpn\n    decision: deny\n    when:\n      proxy: true\n", 6, `unknown condition "proxy"`},
+		{"Flag not boolean", "version: 1\nrules:\n  - reason: vpn\n    decision: deny\n    when: {vpn: maybe}\n", 5, "vpn must be true or false"},
+		{"Score range", "version: 1\nrules:\n  - reason: score\n    decision: deny\n    when:\n      score: {min: 0.5, max: 0.2}\n", 6, "score min must be lower than max"},
+		{"Geolocation", "version: 1\nrules:\n  - reason: far\n    decision: deny\n    when:\n      geolocation: {latitude: 95, longitude: 0, radius_km: 1}\n", 6, "latitude must be between -90 and 90"},
+		{"Duplicate", "version: 1\nversion: 2\n", 2, `duplicate field "version"`},
+	}
+	for _, test := range invalid {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse([]byte(test.content))
+			policyErr := &Error{}
+			assert.True(t, errors.As(err, &policyErr))
+			assert.Equal(t, policyErr.Line, test.line)
+			assert.Equal(t, policyErr.Msg, test.msg)
+		})
+	}
+	t.Run("Error message", func(t *testing.T) {
+		err := &Error{Path: "policy.yaml", Line: 4, Msg: "unknown decision"}
+		assert.Equal(t, err.Error(), "invalid policy policy.yaml:line 4: unknown decision")
+	})
+}
@@ -0,0 +1,100 @@
+// Package policy turns the device signals returned by Fazpass into allow,
+// challenge or deny decisions.
+package policy
+
+import (
+	fazpass "github.com/anvarisy/go-fazpass-sdk"
+)
+
+type (
+	Decision = fazpass.Decision
+	Verdict  = fazpass.Verdict
+)
+
+const (
+	Allow     = fazpass.Allow
+	Challenge = fazpass.Challenge
+	Deny      = fazpass.Deny
+)
+
+// Reason codes reported by the conditions of this package.
+const (
+	ReasonRooted        = "rooted"
+	ReasonEmulator      = "emulator"
+	ReasonGpsSpoof      = "gps_spoof"
+	ReasonAppTemper     = "app_temper"
+	ReasonVpn           = "vpn"
+	ReasonScreenSharing = "screen_sharing"
+	ReasonDebugging     = "debugging"
+	ReasonLowScore      = "low_score"
+	ReasonNoData        = "no_data"
+)
+
+// Condition tells whether a rule applies to a device.
+type Condition func(device *fazpass.Device) bool
+
+// Rule yields Decision with Reason when its condition holds.
+type Rule struct {
+	Reason   string
+	Decision Decision
+	When     Condition
+}
+
+// When returns a rule yielding decision with reason when condition holds.
+func When(decision Decision, reason string, condition Condition) Rule {
+	return Rule{Reason: reason, Decision: decision, When: condition}
+}
+
+// Policy evaluates every rule against a device. The verdict is the most
+// severe decision among the matching rules, with their reasons in rule order,
+// or Default when no rule matches.
+type Policy struct {
+	Rules   []Rule
+	Default Decision
+	// Version is the version of the file the policy was loaded from, zero
+	// for a policy built in code.
+	Version int
+}
+
+// New returns a policy allowing devices no rule matches.
+func New(rules ...Rule) *Policy {
+	return &Policy{Rules: rules, Default: Allow}
+}
+
+// Default returns a conservative policy: rooted, emulated and
+// tampered devices are denied, spoofed locations, debuggers, screen sharing,
+// VPNs and scores below 0.5 are challenged.
+func Default() *Policy {
+	return New(
+		When(Deny, ReasonRooted, Rooted),
+		When(Deny, ReasonEmulator, Emulator),
+		When(Deny, ReasonAppTemper, AppTemper),
+		When(Challenge, ReasonGpsSpoof, GpsSpoof),
+		When(Challenge, ReasonDebugging, Debugging),
+		When(Challenge, ReasonScreenSharing, ScreenSharing),
+		When(Challenge, ReasonVpn, Vpn),
+		When(Challenge, ReasonLowScore, ScoreBelow(0.5)),
+	)
+}
+
+// Evaluate implements fazpass.Evaluator. Missing data is denied.
+func (p *Policy) Evaluate(data *fazpass.Data) Verdict {
+	if data == nil {
+		return Verdict{Decision: Deny, Reasons: []string{ReasonNoData}}
+	}
+	verdict := Verdict{Decision: p.Default}
+	matched := false
+	for _, rule := range p.Rules {
+		if rule.When == nil || !rule.When(&data.Device) {
+			continue
+		}
+		if !matched || rule.Decision > verdict.Decision {
+			verdict.Decision = rule.Decision
+		}
+		matched = true
+		if rule.Reason != "" {
+			verdict.Reasons = append(verdict.Reasons, rule.Reason)
+		}
+	}
+	return verdict
+}
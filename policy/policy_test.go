@@ -0,0 +1,44 @@
+package policy
+
+import (
+	"testing"
+
+	fazpass "github.com/anvarisy/go-fazpass-sdk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicy(t *testing.T) {
+	t.Run("Default allows a clean device", func(t *testing.T) {
+		verdict := Default().Evaluate(&fazpass.Data{Device: fazpass.Device{Score: 1}})
+		assert.Equal(t, verdict, Verdict{Decision: Allow})
+	})
+	t.Run("Default challenges", func(t *testing.T) {
+		verdict := Default().Evaluate(&fazpass.Data{Device: fazpass.Device{Score: 0.2, IsVpn: true}})
+		assert.Equal(t, verdict.Decision, Challenge)
+		assert.Equal(t, verdict.Reasons, []string{ReasonVpn, ReasonLowScore})
+	})
+	t.Run("Most severe decision wins", func(t *testing.T) {
+		verdict := Default().Evaluate(&fazpass.Data{Device: fazpass.Device{Score: 1, IsGpsSpoof: true, IsRooted: true}})
+		assert.Equal(t, verdict.Decision, Deny)
+		assert.Equal(t, verdict.Reasons, []string{ReasonRooted, ReasonGpsSpoof})
+	})
+	t.Run("Missing data", func(t *testing.T) {
+		verdict := Default().Evaluate(nil)
+		assert.Equal(t, verdict.Decision, Deny)
+		assert.Equal(t, verdict.Reasons, []string{ReasonNoData})
+	})
+	t.Run("Composed rules", func(t *testing.T) {
+		p := New(
+			When(Deny, "rooted_android", All(Rooted, Platform("android"))),
+			When(Challenge, "unsafe", Any(Emulator, Not(ScoreBelow(0.9)))),
+		)
+		assert.Equal(t, p.Evaluate(&fazpass.Data{Device: fazpass.Device{IsRooted: true, Platform: "ios"}}).Decision, Allow)
+		assert.Equal(t, p.Evaluate(&fazpass.Data{Device: fazpass.Device{IsRooted: true, Platform: "android"}}).Reasons, []string{"rooted_android"})
+		assert.Equal(t, p.Evaluate(&fazpass.Data{Device: fazpass.Device{Score: 0.95}}).Decision, Challenge)
+	})
+	t.Run("Matching rule overrides default", func(t *testing.T) {
+		p := &Policy{Rules: []Rule{When(Allow, "trusted", Platform("ios"))}, Default: Challenge}
+		assert.Equal(t, p.Evaluate(&fazpass.Data{Device: fazpass.Device{Platform: "ios"}}).Decision, Allow)
+		assert.Equal(t, p.Evaluate(&fazpass.Data{Device: fazpass.Device{Platform: "android"}}).Decision, Challenge)
+	})
+}
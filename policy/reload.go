@@ -0,0 +1,128 @@
+package policy
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	fazpass "github.com/anvarisy/go-fazpass-sdk"
+)
+
+// WatchConfig configures File.Watch.
+type WatchConfig struct {
+	// Interval between polls, fazpass.DefaultWatchInterval when zero.
+	Interval time.Duration
+	// OnReload is called after a changed file was loaded.
+	OnReload func(p *Policy)
+	// OnError is called when a changed file cannot be loaded, the previous
+	// policy is kept.
+	OnError func(err error)
+}
+
+// File is a policy loaded from a file which can be reloaded while in use. It
+// implements fazpass.Evaluator with the policy last loaded successfully.
+type File struct {
+	path string
+
+	mu     sync.RWMutex
+	policy *Policy
+	// sum is the hash of the content last read.
+	sum [sha256.Size]byte
+}
+
+// Open loads the policy file at path.
+func Open(path string) (*File, error) {
+	f := &File{path: path}
+	if _, err := f.Reload(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Path returns the path of the file.
+func (f *File) Path() string {
+	return f.path
+}
+
+// Policy returns the policy last loaded successfully.
+func (f *File) Policy() *Policy {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.policy
+}
+
+// Evaluate evaluates data with the policy last loaded successfully.
+func (f *File) Evaluate(data *fazpass.Data) Verdict {
+	return f.Policy().Evaluate(data)
+}
+
+// Reload loads the file again when its content changed since the previous
+// call and tells whether it did. When the file is invalid, or holds a version
+// older than the current policy, the current policy is kept and an error is
+// returned.
+func (f *File) Reload() (bool, error) {
+	content, err := os.ReadFile(f.path)
+	if err != nil {
+		return false, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	sum := sha256.Sum256(content)
+	if f.policy != nil && sum == f.sum {
+		return false, nil
+	}
+	// Remember the content even when invalid, so it is reported only once.
+	f.sum = sum
+	p, err := Parse(content)
+	if err != nil {
+		if policyErr, ok := err.(*Error); ok {
+			policyErr.Path = f.path
+		}
+		return false, err
+	}
+	if f.policy != nil && p.Version < f.policy.Version {
+		return false, &Error{Path: f.path, Msg: fmt.Sprintf("version %d is older than the current version %d", p.Version, f.policy.Version)}
+	}
+	f.policy = p
+	return true, nil
+}
+
+// Watch polls the file until ctx is done and loads it when its content
+// changes.
+func (f *File) Watch(ctx context.Context, config WatchConfig) {
+	interval := config.Interval
+	if interval <= 0 {
+		interval = fazpass.DefaultWatchInterval
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				f.poll(config)
+			}
+		}
+	}()
+}
+
+func (f *File) poll(config WatchConfig) {
+	reloaded, err := f.Reload()
+	switch {
+	case os.IsNotExist(err):
+		// The file may be missing for a moment while it is replaced.
+	case err != nil:
+		if config.OnError != nil {
+			config.OnError(err)
+		}
+	case reloaded:
+		if config.OnReload != nil {
+			config.OnReload(f.Policy())
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package policy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	fazpass "github.com/anvarisy/go-fazpass-sdk"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	denyVpn      = "version: 1\nrules:\n  - {reason: vpn, decision: deny, when: {vpn: true}}\n"
+	challengeVpn = "version: 2\nrules:\n  - {reason: vpn, decision: challenge, when: {vpn: true}}\n"
+)
+
+func TestFile(t *testing.T) {
+	vpn := &fazpass.Data{Device: fazpass.Device{IsVpn: true}}
+	t.Run("Reload", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "policy.yaml")
+		os.WriteFile(path, []byte(denyVpn), 0600)
+		f, err := Open(path)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, f.Evaluate(vpn).Decision, Deny)
+
+		reloaded, err := f.Reload()
+		assert.Equal(t, err, nil)
+		assert.False(t, reloaded)
+
+		os.WriteFile(path, []byte(challengeVpn), 0600)
+		reloaded, err = f.Reload()
+		assert.Equal(t, err, nil)
+		assert.True(t, reloaded)
+		assert.Equal(t, f.Policy().Version, 2)
+		assert.Equal(t, f.Evaluate(vpn).Decision, Challenge)
+	})
+	t.Run("Invalid file keeps previous policy", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "policy.yaml")
+		os.WriteFile(path, []byte(challengeVpn), 0600)
+		f, _ := Open(path)
+
+		os.WriteFile(path, []byte("version: 3\nrules: [{reason: vpn}]\n"), 0600)
+		_, err := f.Reload()
+		assert.Equal(t, err.Error(), "invalid policy "+path+`:line 2: rule "vpn" without decision`)
+		assert.Equal(t, f.Evaluate(vpn).Decision, Challenge)
+
+		os.WriteFile(path, []byte(denyVpn), 0600)
+		_, err = f.Reload()
+		assert.Equal(t, err.Error(), "invalid policy "+path+": version 1 is older than the current version 2")
+		assert.Equal(t, f.Evaluate(vpn).Decision, Challenge)
+	})
+	t.Run("Open invalid file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "policy.yaml")
+		os.WriteFile(path, []byte("rules: []\n"), 0600)
+		_, err := Open(path)
+		assert.NotEqual(t, err, nil)
+	})
+	t.Run("Watch", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "policy.yaml")
+		os.WriteFile(path, []byte(denyVpn), 0600)
+		f, _ := Open(path)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		reloads := make(chan *Policy, 1)
+		errs := make(chan error, 1)
+		f.Watch(ctx, WatchConfig{
+			Interval: 10 * time.Millisecond,
+			OnReload: func(p *Policy) { reloads <- p },
+			OnError:  func(err error) { errs <- err },
+		})
+
+		os.WriteFile(path, []byte("version: 2\nrules: oops\n"), 0600)
+		select {
+		case err := <-errs:
+			assert.Contains(t, err.Error(), "expected a list of rules")
+		case <-time.After(time.Second):
+			t.Fatal("invalid policy not reported")
+		}
+		assert.Equal(t, f.Evaluate(vpn).Decision, Deny)
+
+		os.WriteFile(path, []byte(challengeVpn), 0600)
+		select {
+		case p := <-reloads:
+			assert.Equal(t, p.Version, 2)
+		case <-time.After(time.Second):
+			t.Fatal("policy not reloaded")
+		}
+		assert.Equal(t, f.Evaluate(vpn).Decision, Challenge)
+	})
+}
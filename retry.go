@@ -0,0 +1,163 @@
+package fazpass
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy controls how SendingData retries transport errors and
+// retryable statuses. Only RetryablePaths are retried, unless RetryUnsafe is
+// set or the request carries an idempotency key, see ContextWithIdempotencyKey.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry, doubled on every retry.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between attempts. A Retry-After header asking
+	// for a longer wait stops the retries.
+	MaxDelay time.Duration
+	// Jitter is the fraction, between 0 and 1, of each delay that is randomized.
+	Jitter float64
+	// RetryableStatuses are the response statuses worth another attempt.
+	RetryableStatuses []int
+	// RetryablePaths are the endpoints that are safe to send twice.
+	RetryablePaths []string
+	// RetryUnsafe allows retrying every endpoint, including /enroll and /remove.
+	RetryUnsafe bool
+}
+
+// DefaultRetryPolicy retries /check and /validate up to three times on
+// transport errors, 502, 503 and 504.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:       3,
+		BaseDelay:         100 * time.Millisecond,
+		MaxDelay:          2 * time.Second,
+		Jitter:            0.2,
+		RetryableStatuses: []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+		RetryablePaths:    []string{"/check", "/validate"},
+	}
+}
+
+// WithRetryPolicy makes the flow retry failed requests according to policy.
+func WithRetryPolicy(policy RetryPolicy) FlowOption {
+	return func(flow *Flow) {
+		flow.retry = &policy
+	}
+}
+
+type idempotencyKey struct{}
+
+// ContextWithIdempotencyKey attaches an idempotency key to the requests sent
+// with ctx. It is sent as the Idempotency-Key header and allows retrying
+// endpoints that are not idempotent by themselves.
+func ContextWithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+func idempotencyKeyFrom(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKey{}).(string)
+	return key
+}
+
+// attempts returns how many times the request to baseUrl may be sent.
+func (policy *RetryPolicy) attempts(baseUrl string, idempotent bool) int {
+	if policy == nil || policy.MaxAttempts < 1 {
+		return 1
+	}
+	if policy.RetryUnsafe || idempotent {
+		return policy.MaxAttempts
+	}
+	path := baseUrl
+	if u, err := url.Parse(baseUrl); err == nil {
+		path = u.Path
+	}
+	for _, retryable := range policy.RetryablePaths {
+		if strings.HasSuffix(path, retryable) {
+			return policy.MaxAttempts
+		}
+	}
+	return 1
+}
+
+func (policy *RetryPolicy) retryable(response *http.Response, err error) bool {
+	if err != nil {
+		return errors.Is(err, ErrTransport)
+	}
+	for _, status := range policy.RetryableStatuses {
+		if response.StatusCode == status {
+			return true
+		}
+	}
+	return false
+}
+
+// delay returns how long to wait before the next attempt, and false when the
+// server asks to wait longer than MaxDelay.
+func (policy *RetryPolicy) delay(attempt int, response *http.Response) (time.Duration, bool) {
+	delay := policy.BaseDelay << (attempt - 1)
+	if delay <= 0 || (policy.MaxDelay > 0 && delay > policy.MaxDelay) {
+		delay = policy.MaxDelay
+	}
+	if policy.Jitter > 0 && delay > 0 {
+		delay -= time.Duration(rand.Float64() * policy.Jitter * float64(delay))
+	}
+	if response != nil {
+		if retryAfter, ok := parseRetryAfter(response.Header.Get("Retry-After")); ok {
+			if policy.MaxDelay > 0 && retryAfter > policy.MaxDelay {
+				return 0, false
+			}
+			if retryAfter > delay {
+				delay = retryAfter
+			}
+		}
+	}
+	return delay, true
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as
+// an HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
+
+// wait sleeps for delay, returning early with the context error if ctx is done.
+func wait(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// discard drains and closes the body of a response that is not returned.
+func discard(response *http.Response) {
+	if response == nil {
+		return
+	}
+	io.Copy(io.Discard, io.LimitReader(response.Body, 64<<10))
+	response.Body.Close()
+}
@@ -0,0 +1,117 @@
+package fazpass
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func flakyServer(failures int32, status int, hits *int32, keys chan string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if keys != nil {
+			keys <- r.Header.Get("Idempotency-Key")
+		}
+		if atomic.AddInt32(hits, 1) <= failures {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(status)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+func TestRetry(t *testing.T) {
+	policy := DefaultRetryPolicy()
+	policy.BaseDelay = time.Millisecond
+	policy.MaxDelay = 10 * time.Millisecond
+
+	t.Run("Retry check until success", func(t *testing.T) {
+		var hits int32
+		server := flakyServer(2, http.StatusServiceUnavailable, &hits, nil)
+		defer server.Close()
+		f := NewFlow(WithRetryPolicy(policy))
+		resp, err := f.SendingData(server.URL+"/check", []byte("{}"), "MERCHANT_KEY")
+		assert.Equal(t, err, nil)
+		assert.Equal(t, resp.StatusCode, http.StatusOK)
+		assert.Equal(t, hits, int32(3))
+	})
+	t.Run("Give up after max attempts", func(t *testing.T) {
+		var hits int32
+		server := flakyServer(5, http.StatusBadGateway, &hits, nil)
+		defer server.Close()
+		f := NewFlow(WithRetryPolicy(policy))
+		resp, err := f.SendingData(server.URL+"/validate", []byte("{}"), "MERCHANT_KEY")
+		assert.Equal(t, err, nil)
+		assert.Equal(t, resp.StatusCode, http.StatusBadGateway)
+		assert.Equal(t, hits, int32(3))
+	})
+	t.Run("Status not retryable", func(t *testing.T) {
+		var hits int32
+		server := flakyServer(1, http.StatusInternalServerError, &hits, nil)
+		defer server.Close()
+		f := NewFlow(WithRetryPolicy(policy))
+		resp, _ := f.SendingData(server.URL+"/check", []byte("{}"), "MERCHANT_KEY")
+		assert.Equal(t, resp.StatusCode, http.StatusInternalServerError)
+		assert.Equal(t, hits, int32(1))
+	})
+	t.Run("Enroll not retried", func(t *testing.T) {
+		var hits int32
+		server := flakyServer(1, http.StatusServiceUnavailable, &hits, nil)
+		defer server.Close()
+		f := NewFlow(WithRetryPolicy(policy))
+		resp, _ := f.SendingData(server.URL+"/enroll", []byte("{}"), "MERCHANT_KEY")
+		assert.Equal(t, resp.StatusCode, http.StatusServiceUnavailable)
+		assert.Equal(t, hits, int32(1))
+	})
+	t.Run("Enroll retried with idempotency key", func(t *testing.T) {
+		var hits int32
+		keys := make(chan string, 2)
+		server := flakyServer(1, http.StatusServiceUnavailable, &hits, keys)
+		defer server.Close()
+		f := NewFlow(WithRetryPolicy(policy))
+		ctx := ContextWithIdempotencyKey(context.Background(), "KOALA_PANDA")
+		resp, _ := f.SendingDataContext(ctx, server.URL+"/enroll", []byte("{}"), "MERCHANT_KEY")
+		assert.Equal(t, resp.StatusCode, http.StatusOK)
+		assert.Equal(t, hits, int32(2))
+		assert.Equal(t, <-keys, "KOALA_PANDA")
+		assert.Equal(t, <-keys, "KOALA_PANDA")
+	})
+	t.Run("Retry-After too long", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", "120")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+		f := NewFlow(WithRetryPolicy(policy))
+		start := time.Now()
+		resp, _ := f.SendingData(server.URL+"/check", []byte("{}"), "MERCHANT_KEY")
+		assert.Equal(t, resp.StatusCode, http.StatusServiceUnavailable)
+		assert.True(t, time.Since(start) < time.Second)
+	})
+	t.Run("Context canceled while waiting", func(t *testing.T) {
+		var hits int32
+		server := flakyServer(5, http.StatusServiceUnavailable, &hits, nil)
+		defer server.Close()
+		slow := policy
+		slow.BaseDelay = time.Second
+		slow.MaxDelay = time.Second
+		f := NewFlow(WithRetryPolicy(slow))
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err := f.SendingDataContext(ctx, server.URL+"/check", []byte("{}"), "MERCHANT_KEY")
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	})
+	t.Run("Retry-After header", func(t *testing.T) {
+		delay, ok := parseRetryAfter("3")
+		assert.True(t, ok)
+		assert.Equal(t, delay, 3*time.Second)
+		_, ok = parseRetryAfter("KOALA_PANDA")
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,134 @@
+package fazpass
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ShadowResult is a shadow policy verdict disagreeing with the enforced one.
+type ShadowResult struct {
+	// Name is the name the shadow policy was registered with.
+	Name string
+	// Path is the endpoint whose result was evaluated, "/check" or "/validate".
+	Path     string
+	Data     *Data
+	Enforced Verdict
+	Shadow   Verdict
+}
+
+// ShadowStats counts the evaluations of a shadow policy.
+type ShadowStats struct {
+	Evaluated int64
+	// Disagreed counts the verdicts whose decision differs from the enforced one.
+	Disagreed int64
+	// Panicked counts the evaluations that panicked, they are not counted as
+	// evaluated.
+	Panicked int64
+	// Decisions counts the decisions the shadow policy would have made.
+	Decisions map[Decision]int64
+}
+
+type shadowPolicy struct {
+	name      string
+	evaluator Evaluator
+
+	mu    sync.Mutex
+	stats ShadowStats
+}
+
+// WithShadowPolicy evaluates evaluator on the result of every successful Check
+// and ValidateDevice besides the enforced policy, without affecting what the
+// client returns. Disagreements are counted in ShadowStats and reported to the
+// WithShadowDisagreement callback.
+func WithShadowPolicy(name string, evaluator Evaluator) Option {
+	return func(f *Fazpass) error {
+		if name == "" {
+			return errors.New("shadow policy name cannot be empty")
+		}
+		if evaluator == nil {
+			return errors.New("shadow policy cannot be nil")
+		}
+		for _, shadow := range f.shadows {
+			if shadow.name == name {
+				return fmt.Errorf("shadow policy %q already set", name)
+			}
+		}
+		f.shadows = append(f.shadows, &shadowPolicy{name: name, evaluator: evaluator})
+		return nil
+	}
+}
+
+// WithShadowDisagreement calls fn whenever a shadow policy decides otherwise
+// than the enforced policy. fn is called synchronously, so it should be quick.
+func WithShadowDisagreement(fn func(result ShadowResult)) Option {
+	return func(f *Fazpass) error {
+		f.onDisagreement = fn
+		return nil
+	}
+}
+
+// ShadowStats returns the statistics of each shadow policy, by name.
+func (f *Fazpass) ShadowStats() map[string]ShadowStats {
+	stats := make(map[string]ShadowStats, len(f.shadows))
+	for _, shadow := range f.shadows {
+		shadow.mu.Lock()
+		snapshot := shadow.stats
+		snapshot.Decisions = make(map[Decision]int64, len(shadow.stats.Decisions))
+		for decision, count := range shadow.stats.Decisions {
+			snapshot.Decisions[decision] = count
+		}
+		shadow.mu.Unlock()
+		stats[shadow.name] = snapshot
+	}
+	return stats
+}
+
+// shadow evaluates the shadow policies on data. Without an enforced verdict,
+// the one of f.Policy is used, or Allow when no policy is set.
+func (f *Fazpass) shadow(path string, data *Data, enforced *Verdict) {
+	if len(f.shadows) == 0 {
+		return
+	}
+	if enforced == nil {
+		enforced = &Verdict{Decision: Allow}
+		if f.Policy != nil {
+			verdict := f.Policy.Evaluate(data)
+			enforced = &verdict
+		}
+	}
+	for _, shadow := range f.shadows {
+		verdict, ok := shadow.evaluate(data)
+		if !ok {
+			continue
+		}
+		disagreed := verdict.Decision != enforced.Decision
+		shadow.mu.Lock()
+		shadow.stats.Evaluated++
+		if shadow.stats.Decisions == nil {
+			shadow.stats.Decisions = map[Decision]int64{}
+		}
+		shadow.stats.Decisions[verdict.Decision]++
+		if disagreed {
+			shadow.stats.Disagreed++
+		}
+		shadow.mu.Unlock()
+		if disagreed && f.onDisagreement != nil {
+			f.onDisagreement(ShadowResult{Name: shadow.name, Path: path, Data: cloneData(data), Enforced: *enforced, Shadow: verdict})
+		}
+	}
+}
+
+// evaluate runs the shadow policy on a copy of data, so it cannot alter what
+// the caller gets, and recovers from its panics.
+func (shadow *shadowPolicy) evaluate(data *Data) (verdict Verdict, ok bool) {
+	defer func() {
+		if recover() != nil {
+			shadow.mu.Lock()
+			shadow.stats.Panicked++
+			shadow.mu.Unlock()
+			ok = false
+		}
+	}()
+	return shadow.evaluator.Evaluate(cloneData(data)), true
+}
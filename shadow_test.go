@@ -0,0 +1,87 @@
+package fazpass
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShadowPolicy(t *testing.T) {
+	denyVpn := evaluatorFunc(func(data *Data) Verdict {
+		if data.Device.IsVpn {
+			return Verdict{Decision: Deny, Reasons: []string{"vpn"}}
+		}
+		return Verdict{Decision: Allow}
+	})
+	challengeAll := evaluatorFunc(func(data *Data) Verdict {
+		return Verdict{Decision: Challenge}
+	})
+
+	t.Run("Disagreement", func(t *testing.T) {
+		var results []ShadowResult
+		f := testClient(t, testFlow(&Data{SessionId: "1", Device: Device{IsVpn: true}}, nil),
+			WithShadowPolicy("strict", denyVpn),
+			WithShadowPolicy("challenge", challengeAll),
+			WithShadowDisagreement(func(result ShadowResult) { results = append(results, result) }))
+		data, err := f.Check("anvarisy@gmail.com", "085811752000", "KOALA_PANDA")
+		assert.Equal(t, err, nil)
+		assert.Equal(t, data.SessionId, "1")
+		f.ValidateDevice("FAZPASS_ID", "KOALA_PANDA")
+		f.RemoveDevice("FAZPASS_ID", "KOALA_PANDA")
+
+		assert.Equal(t, len(results), 4)
+		assert.Equal(t, results[0].Name, "strict")
+		assert.Equal(t, results[0].Path, "/check")
+		assert.Equal(t, results[0].Enforced, Verdict{Decision: Allow})
+		assert.Equal(t, results[0].Shadow, Verdict{Decision: Deny, Reasons: []string{"vpn"}})
+		assert.Equal(t, results[2].Path, "/validate")
+		stats := f.ShadowStats()
+		assert.Equal(t, stats["strict"], ShadowStats{Evaluated: 2, Disagreed: 2, Decisions: map[Decision]int64{Deny: 2}})
+	})
+	t.Run("Enforced policy", func(t *testing.T) {
+		var results []ShadowResult
+		f := testClient(t, testFlow(&Data{SessionId: "1", Device: Device{IsVpn: true}}, nil),
+			WithPolicy(challengeAll),
+			WithShadowPolicy("strict", denyVpn),
+			WithShadowPolicy("same", challengeAll),
+			WithShadowDisagreement(func(result ShadowResult) { results = append(results, result) }))
+		_, verdict, err := f.CheckAndDecide(context.Background(), "anvarisy@gmail.com", "085811752000", "KOALA_PANDA", nil)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, verdict.Decision, Challenge)
+		assert.Equal(t, len(results), 1)
+		assert.Equal(t, results[0].Enforced.Decision, Challenge)
+		assert.Equal(t, f.ShadowStats()["same"].Disagreed, int64(0))
+		assert.Equal(t, f.ShadowStats()["same"].Evaluated, int64(1))
+	})
+	t.Run("Shadow cannot affect result", func(t *testing.T) {
+		f := testClient(t, testFlow(&Data{SessionId: "1", Device: Device{SimSerial: []string{"1"}}}, nil),
+			WithShadowPolicy("panic", evaluatorFunc(func(data *Data) Verdict { panic("broken policy") })),
+			WithShadowPolicy("mutate", evaluatorFunc(func(data *Data) Verdict {
+				data.SessionId = "2"
+				data.Device.SimSerial[0] = "2"
+				return Verdict{Decision: Allow}
+			})),
+			WithShadowPolicy("challenge", challengeAll),
+			WithShadowDisagreement(func(result ShadowResult) {
+				result.Data.SessionId = "3"
+				result.Data.Device.SimSerial[0] = "3"
+			}))
+		_, verdict, err := f.CheckAndDecide(context.Background(), "anvarisy@gmail.com", "085811752000", "KOALA_PANDA", denyVpn)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, verdict.Decision, Allow)
+		data, err := f.Check("anvarisy@gmail.com", "085811752000", "KOALA_PANDA")
+		assert.Equal(t, err, nil)
+		assert.Equal(t, data.SessionId, "1")
+		assert.Equal(t, data.Device.SimSerial, []string{"1"})
+		assert.Equal(t, f.ShadowStats()["panic"], ShadowStats{Panicked: 2, Decisions: map[Decision]int64{}})
+	})
+	t.Run("Invalid options", func(t *testing.T) {
+		_, err := New(WithShadowPolicy("strict", denyVpn), WithShadowPolicy("strict", challengeAll))
+		assert.Equal(t, err.Error(), `shadow policy "strict" already set`)
+		_, err = New(WithShadowPolicy("", denyVpn))
+		assert.Equal(t, err.Error(), "shadow policy name cannot be empty")
+		_, err = New(WithPolicy(nil))
+		assert.Equal(t, err.Error(), "policy cannot be nil")
+	})
+}
@@ -0,0 +1,361 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// ParsePrivateKey parses an RSA private key of at least MinKeyBits, given
+// either as PEM (PKCS#1, PKCS#8, legacy encrypted PEM or encrypted PKCS#8)
+// or as a JWK or JWK set. The password is only used for encrypted keys.
+func ParsePrivateKey(data []byte, password []byte) (*rsa.PrivateKey, error) {
+	key, err := parsePrivateKey(data, password)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkKeySize(&key.PublicKey); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// ParsePublicKey parses an RSA public key of at least MinKeyBits, given
+// either as PEM (PKIX, PKCS#1 or a certificate) or as a JWK or JWK set.
+func ParsePublicKey(data []byte) (*rsa.PublicKey, error) {
+	key, err := parsePublicKey(data)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkKeySize(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func checkKeySize(key *rsa.PublicKey) error {
+	if bits := key.N.BitLen(); bits < MinKeyBits {
+		return fmt.Errorf("key size %d is below the minimum of %d bits", bits, MinKeyBits)
+	}
+	return nil
+}
+
+func isJSON(data []byte) bool {
+	trimmed := bytes.TrimSpace(data)
+	return len(trimmed) > 0 && trimmed[0] == '{'
+}
+
+func parsePrivateKey(data []byte, password []byte) (*rsa.PrivateKey, error) {
+	if isJSON(data) {
+		return parsePrivateJWK(data)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("private key is neither PEM nor JWK")
+	}
+	der := block.Bytes
+	// Legacy encrypted PEM is deprecated but still what openssl rsa -aes256 produces.
+	encrypted := x509.IsEncryptedPEMBlock(block)
+	if encrypted {
+		if len(password) == 0 {
+			return nil, errors.New("private key is encrypted, a password is required")
+		}
+		var err error
+		der, err = x509.DecryptPEMBlock(block, password)
+		if err != nil {
+			return nil, fmt.Errorf("cannot decrypt private key: %w", err)
+		}
+	}
+	switch block.Type {
+	case "ENCRYPTED PRIVATE KEY":
+		if len(password) == 0 {
+			return nil, errors.New("private key is encrypted, a password is required")
+		}
+		decrypted, err := decryptPKCS8(der, password)
+		if err != nil {
+			return nil, err
+		}
+		return parsePKCS8(decrypted)
+	case "PRIVATE KEY":
+		return parsePKCS8(der)
+	case "RSA PRIVATE KEY":
+		key, err := x509.ParsePKCS1PrivateKey(der)
+		if err != nil {
+			if encrypted {
+				return nil, errors.New("cannot decrypt private key: wrong password")
+			}
+			return nil, fmt.Errorf("invalid PKCS#1 private key: %w", err)
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported private key PEM type %q", block.Type)
+	}
+}
+
+func parsePKCS8(der []byte) (*rsa.PrivateKey, error) {
+	parsed, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PKCS#8 private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key is %T, not RSA", parsed)
+	}
+	return key, nil
+}
+
+func parsePublicKey(data []byte) (*rsa.PublicKey, error) {
+	if isJSON(data) {
+		return parsePublicJWK(data)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("public key is neither PEM nor JWK")
+	}
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		cert, err = x509.ParseCertificate(block.Bytes)
+		if err == nil {
+			parsed = cert.PublicKey
+		}
+	default:
+		// Keys are found labelled "RSA PUBLIC KEY" while holding PKIX, so
+		// try both encodings whatever the label.
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			if key, errPKCS1 := x509.ParsePKCS1PublicKey(block.Bytes); errPKCS1 == nil {
+				parsed, err = key, nil
+			}
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key is %T, not RSA", parsed)
+	}
+	return key, nil
+}
+
+// jwk holds the RSA members of a JSON Web Key, RFC 7518 section 6.3.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	D   string `json:"d"`
+	P   string `json:"p"`
+	Q   string `json:"q"`
+	Dp  string `json:"dp"`
+	Dq  string `json:"dq"`
+	Qi  string `json:"qi"`
+}
+
+// readJWK reads a JWK, or the first RSA key of a JWK set.
+func readJWK(data []byte, private bool) (*jwk, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+		jwk
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("invalid JWK: %w", err)
+	}
+	if set.Keys == nil {
+		set.Keys = []jwk{set.jwk}
+	}
+	for i := range set.Keys {
+		key := &set.Keys[i]
+		if key.Kty == "RSA" && (!private || key.D != "") {
+			return key, nil
+		}
+	}
+	if private {
+		return nil, errors.New("JWK holds no RSA private key")
+	}
+	return nil, errors.New("JWK holds no RSA public key")
+}
+
+func parsePublicJWK(data []byte) (*rsa.PublicKey, error) {
+	key, err := readJWK(data, false)
+	if err != nil {
+		return nil, err
+	}
+	return key.publicKey()
+}
+
+func parsePrivateJWK(data []byte) (*rsa.PrivateKey, error) {
+	key, err := readJWK(data, true)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := key.publicKey()
+	if err != nil {
+		return nil, err
+	}
+	priv := &rsa.PrivateKey{PublicKey: *pub}
+	if priv.D, err = jwkInt("d", key.D); err != nil {
+		return nil, err
+	}
+	if key.P != "" && key.Q != "" {
+		p, err := jwkInt("p", key.P)
+		if err != nil {
+			return nil, err
+		}
+		q, err := jwkInt("q", key.Q)
+		if err != nil {
+			return nil, err
+		}
+		priv.Primes = []*big.Int{p, q}
+	}
+	if len(priv.Primes) == 0 {
+		return nil, errors.New("JWK private key without primes is not supported")
+	}
+	if err = priv.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid JWK private key: %w", err)
+	}
+	priv.Precompute()
+	return priv, nil
+}
+
+func (key *jwk) publicKey() (*rsa.PublicKey, error) {
+	n, err := jwkInt("n", key.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := jwkInt("e", key.E)
+	if err != nil {
+		return nil, err
+	}
+	if !e.IsInt64() || e.Int64() > 1<<31-1 || e.Int64() < 3 {
+		return nil, errors.New("invalid JWK exponent")
+	}
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+}
+
+func jwkInt(name string, value string) (*big.Int, error) {
+	if value == "" {
+		return nil, fmt.Errorf("JWK member %q is missing", name)
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("JWK member %q is not base64url: %w", name, err)
+	}
+	return new(big.Int).SetBytes(decoded), nil
+}
+
+var (
+	oidPBES2          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidAES128CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// decryptPKCS8 decrypts an encrypted PKCS#8 key using PBES2 with PBKDF2 and
+// AES-CBC, the scheme used by openssl pkcs8 -topk8 -v2 aes256.
+func decryptPKCS8(der []byte, password []byte) ([]byte, error) {
+	info := &encryptedPrivateKeyInfo{}
+	if _, err := asn1.Unmarshal(der, info); err != nil {
+		return nil, fmt.Errorf("invalid encrypted PKCS#8 private key: %w", err)
+	}
+	if !info.Algorithm.Algorithm.Equal(oidPBES2) {
+		return nil, fmt.Errorf("unsupported PKCS#8 encryption %v, only PBES2 is supported", info.Algorithm.Algorithm)
+	}
+	params := &pbes2Params{}
+	if _, err := asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, params); err != nil {
+		return nil, fmt.Errorf("invalid PBES2 parameters: %w", err)
+	}
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, fmt.Errorf("unsupported key derivation %v, only PBKDF2 is supported", params.KeyDerivationFunc.Algorithm)
+	}
+	kdf := &pbkdf2Params{}
+	if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, kdf); err != nil {
+		return nil, fmt.Errorf("invalid PBKDF2 parameters: %w", err)
+	}
+	var prf func() hash.Hash
+	switch {
+	case len(kdf.PRF.Algorithm) == 0, kdf.PRF.Algorithm.Equal(oidHMACWithSHA1):
+		prf = sha1.New
+	case kdf.PRF.Algorithm.Equal(oidHMACWithSHA256):
+		prf = sha256.New
+	default:
+		return nil, fmt.Errorf("unsupported PBKDF2 function %v", kdf.PRF.Algorithm)
+	}
+	var keyLength int
+	switch {
+	case params.EncryptionScheme.Algorithm.Equal(oidAES128CBC):
+		keyLength = 16
+	case params.EncryptionScheme.Algorithm.Equal(oidAES192CBC):
+		keyLength = 24
+	case params.EncryptionScheme.Algorithm.Equal(oidAES256CBC):
+		keyLength = 32
+	default:
+		return nil, fmt.Errorf("unsupported PKCS#8 cipher %v", params.EncryptionScheme.Algorithm)
+	}
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil || len(iv) != aes.BlockSize {
+		return nil, errors.New("invalid AES-CBC parameters")
+	}
+	encrypted := info.EncryptedData
+	if len(encrypted) == 0 || len(encrypted)%aes.BlockSize != 0 {
+		return nil, errors.New("invalid encrypted PKCS#8 data length")
+	}
+
+	key := pbkdf2.Key(password, kdf.Salt, kdf.IterationCount, keyLength, prf)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	decrypted := make([]byte, len(encrypted))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(decrypted, encrypted)
+
+	// A wrong password shows up as invalid PKCS#7 padding.
+	padding := int(decrypted[len(decrypted)-1])
+	if padding == 0 || padding > aes.BlockSize {
+		return nil, errors.New("cannot decrypt private key: wrong password")
+	}
+	for _, b := range decrypted[len(decrypted)-padding:] {
+		if int(b) != padding {
+			return nil, errors.New("cannot decrypt private key: wrong password")
+		}
+	}
+	return decrypted[:len(decrypted)-padding], nil
+}
@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readTestdata(t *testing.T, name string) []byte {
+	content, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+func toJWK(key *rsa.PrivateKey, private bool) map[string]string {
+	encode := func(i *big.Int) string {
+		return base64.RawURLEncoding.EncodeToString(i.Bytes())
+	}
+	jwk := map[string]string{
+		"kty": "RSA",
+		"kid": "fazpass",
+		"n":   encode(key.N),
+		"e":   encode(big.NewInt(int64(key.E))),
+	}
+	if private {
+		jwk["d"] = encode(key.D)
+		jwk["p"] = encode(key.Primes[0])
+		jwk["q"] = encode(key.Primes[1])
+	}
+	return jwk
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	expected, err := ParsePrivateKey(readTestdata(t, "pkcs1.pem"), nil)
+	assert.Equal(t, err, nil)
+
+	t.Run("PKCS#8", func(t *testing.T) {
+		key, err := ParsePrivateKey(readTestdata(t, "pkcs8.pem"), nil)
+		assert.Equal(t, err, nil)
+		assert.True(t, key.Equal(expected))
+	})
+	t.Run("Encrypted PKCS#8", func(t *testing.T) {
+		key, err := ParsePrivateKey(readTestdata(t, "pkcs8_encrypted.pem"), []byte("fazpass"))
+		assert.Equal(t, err, nil)
+		assert.True(t, key.Equal(expected))
+	})
+	t.Run("Encrypted PKCS#8 wrong password", func(t *testing.T) {
+		_, err := ParsePrivateKey(readTestdata(t, "pkcs8_encrypted.pem"), []byte("KOALA_PANDA"))
+		assert.Equal(t, err.Error(), "cannot decrypt private key: wrong password")
+	})
+	t.Run("Encrypted PKCS#8 without password", func(t *testing.T) {
+		_, err := ParsePrivateKey(readTestdata(t, "pkcs8_encrypted.pem"), nil)
+		assert.Equal(t, err.Error(), "private key is encrypted, a password is required")
+	})
+	t.Run("Encrypted PEM", func(t *testing.T) {
+		key, err := ParsePrivateKey(readTestdata(t, "pkcs1_encrypted.pem"), []byte("fazpass"))
+		assert.Equal(t, err, nil)
+		assert.True(t, key.Equal(expected))
+	})
+	t.Run("Encrypted PEM without password", func(t *testing.T) {
+		_, err := ParsePrivateKey(readTestdata(t, "pkcs1_encrypted.pem"), nil)
+		assert.Equal(t, err.Error(), "private key is encrypted, a password is required")
+	})
+	t.Run("JWK", func(t *testing.T) {
+		content, _ := json.Marshal(toJWK(expected, true))
+		key, err := ParsePrivateKey(content, nil)
+		assert.Equal(t, err, nil)
+		assert.True(t, key.Equal(expected))
+	})
+	t.Run("JWKS", func(t *testing.T) {
+		content, _ := json.Marshal(map[string]interface{}{
+			"keys": []map[string]string{{"kty": "EC"}, toJWK(expected, true)},
+		})
+		key, err := ParsePrivateKey(content, nil)
+		assert.Equal(t, err, nil)
+		assert.True(t, key.Equal(expected))
+	})
+	t.Run("JWK without private members", func(t *testing.T) {
+		content, _ := json.Marshal(toJWK(expected, false))
+		_, err := ParsePrivateKey(content, nil)
+		assert.Equal(t, err.Error(), "JWK holds no RSA private key")
+	})
+	t.Run("Not PEM", func(t *testing.T) {
+		_, err := ParsePrivateKey([]byte("KOALA_PANDA"), nil)
+		assert.Equal(t, err.Error(), "private key is neither PEM nor JWK")
+	})
+	t.Run("Not RSA", func(t *testing.T) {
+		_, err := ParsePrivateKey(readTestdata(t, "ec.pem"), nil)
+		assert.Equal(t, err.Error(), `unsupported private key PEM type "EC PRIVATE KEY"`)
+	})
+	t.Run("Key too small", func(t *testing.T) {
+		_, err := ParsePrivateKey(readTestdata(t, "small.pem"), nil)
+		assert.Equal(t, err.Error(), "key size 1024 is below the minimum of 2048 bits")
+	})
+	t.Run("Bytes to private key without PEM", func(t *testing.T) {
+		_, err := BytesToPrivateKey(nil)
+		assert.NotEqual(t, err, nil)
+	})
+}
+
+func TestParsePublicKey(t *testing.T) {
+	priv, _ := ParsePrivateKey(readTestdata(t, "pkcs1.pem"), nil)
+	expected := &priv.PublicKey
+
+	for _, name := range []string{"public.pem", "public_pkcs1.pem", "certificate.pem"} {
+		t.Run(name, func(t *testing.T) {
+			key, err := ParsePublicKey(readTestdata(t, name))
+			assert.Equal(t, err, nil)
+			assert.True(t, key.Equal(expected))
+		})
+	}
+	t.Run("JWK", func(t *testing.T) {
+		content, _ := json.Marshal(toJWK(priv, false))
+		key, err := ParsePublicKey(content)
+		assert.Equal(t, err, nil)
+		assert.True(t, key.Equal(expected))
+	})
+	t.Run("Repository key", func(t *testing.T) {
+		_, err := ParsePublicKey(readTestdata(t, "../../key.pub"))
+		assert.Equal(t, err, nil)
+	})
+	t.Run("Not PEM", func(t *testing.T) {
+		_, err := BytesToPublicKey([]byte("KOALA_PANDA"))
+		assert.Equal(t, err.Error(), "public key is neither PEM nor JWK")
+	})
+}
@@ -1,26 +1,71 @@
 package utils
 
 import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/base64"
 	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
 )
 
+// MinKeyBits is the smallest RSA key size accepted by GenerateKeyPair
+const MinKeyBits = 2048
+
+// GenerateKeyPair generates a merchant RSA key pair of the given size
+func GenerateKeyPair(bits int) (*rsa.PrivateKey, error) {
+	if bits < MinKeyBits {
+		return nil, fmt.Errorf("key size %d is below the minimum of %d bits", bits, MinKeyBits)
+	}
+	return rsa.GenerateKey(rand.Reader, bits)
+}
+
+// PrivateKeyToBytes private key to PKCS#1 PEM bytes, as read by BytesToPrivateKey
+func PrivateKeyToBytes(priv *rsa.PrivateKey) []byte {
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(priv),
+	})
+}
+
+// PublicKeyToBytes public key to PKIX PEM bytes, as read by BytesToPublicKey
+func PublicKeyToBytes(pub *rsa.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: der,
+	}), nil
+}
+
+// Fingerprint returns the SHA-256 fingerprint of the PKIX encoded public key
+func Fingerprint(pub *rsa.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:]), nil
+}
+
+// BytesToPrivateKey bytes to private key, see ParsePrivateKey for the
+// accepted formats. Unlike ParsePrivateKey it does not check the key size.
 func BytesToPrivateKey(priv []byte) (*rsa.PrivateKey, error) {
-	block, _ := pem.Decode(priv)
-	b := block.Bytes
-	key, err := x509.ParsePKCS1PrivateKey(b)
-	return key, err
+	return parsePrivateKey(priv, nil)
 }
 
-// BytesToPublicKey bytes to public key
+// BytesToPublicKey bytes to public key, see ParsePublicKey for the accepted
+// formats. Unlike ParsePublicKey it does not check the key size.
 func BytesToPublicKey(pub []byte) (*rsa.PublicKey, error) {
-	block, _ := pem.Decode(pub)
-	b := block.Bytes
-	ifc, err := x509.ParsePKIXPublicKey(b)
-	key, _ := ifc.(*rsa.PublicKey)
-	return key, err
+	return parsePublicKey(pub)
 }
 
 // EncryptWithPublicKey encrypts data with public key
@@ -31,6 +76,71 @@ func EncryptWithPublicKey(msg []byte, pub *rsa.PublicKey) ([]byte, error) {
 
 // DecryptWithPrivateKey decrypts data with private key
 func DecryptWithPrivateKey(ciphertext []byte, priv *rsa.PrivateKey) ([]byte, error) {
-	plaintext, err := rsa.DecryptPKCS1v15(rand.Reader, priv, ciphertext)
-	return plaintext, err
+	return DecryptWithDecrypter(ciphertext, priv)
+}
+
+// DecryptWithDecrypter decrypts RSA PKCS#1 v1.5 data with any decrypter,
+// such as a private key held by a KMS
+func DecryptWithDecrypter(ciphertext []byte, decrypter crypto.Decrypter) ([]byte, error) {
+	return decrypter.Decrypt(rand.Reader, ciphertext, &rsa.PKCS1v15DecryptOptions{})
+}
+
+// EncryptEnvelope encrypts data of any size with a random AES-256-GCM key,
+// which is itself encrypted with the public key using RSA-OAEP (SHA-256).
+// The envelope is the wrapped key followed by the nonce and the sealed data.
+func EncryptEnvelope(msg []byte, pub *rsa.PublicKey) ([]byte, error) {
+	contentKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, contentKey); err != nil {
+		return nil, err
+	}
+	wrappedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, pub, contentKey, nil)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(contentKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	envelope := make([]byte, 0, len(wrappedKey)+len(nonce)+len(msg)+gcm.Overhead())
+	envelope = append(envelope, wrappedKey...)
+	envelope = append(envelope, nonce...)
+	return gcm.Seal(envelope, nonce, msg, nil), nil
+}
+
+// DecryptEnvelope decrypts an envelope produced by EncryptEnvelope, the
+// decrypter being a private key or a key held by a KMS
+func DecryptEnvelope(envelope []byte, decrypter crypto.Decrypter) ([]byte, error) {
+	pub, ok := decrypter.Public().(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("decrypter is not an RSA key")
+	}
+	keySize := pub.Size()
+	if len(envelope) < keySize {
+		return nil, errors.New("envelope too short")
+	}
+	contentKey, err := decrypter.Decrypt(rand.Reader, envelope[:keySize], &rsa.OAEPOptions{Hash: crypto.SHA256})
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(contentKey)
+	if err != nil {
+		return nil, err
+	}
+	rest := envelope[keySize:]
+	if len(rest) < gcm.NonceSize() {
+		return nil, errors.New("envelope too short")
+	}
+	return gcm.Open(nil, rest[:gcm.NonceSize()], rest[gcm.NonceSize():], nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
 }
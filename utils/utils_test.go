@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateKeyPair(t *testing.T) {
+	t.Run("Key too small", func(t *testing.T) {
+		_, err := GenerateKeyPair(1024)
+		assert.NotEqual(t, err, nil)
+	})
+	t.Run("Round trip", func(t *testing.T) {
+		priv, err := GenerateKeyPair(2048)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, priv.N.BitLen(), 2048)
+
+		parsedPriv, err := BytesToPrivateKey(PrivateKeyToBytes(priv))
+		assert.Equal(t, err, nil)
+		assert.True(t, parsedPriv.Equal(priv))
+
+		pub, err := PublicKeyToBytes(&priv.PublicKey)
+		assert.Equal(t, err, nil)
+		parsedPub, err := BytesToPublicKey(pub)
+		assert.Equal(t, err, nil)
+		assert.True(t, parsedPub.Equal(&priv.PublicKey))
+	})
+}
+
+func TestFingerprint(t *testing.T) {
+	pub, _ := os.ReadFile("../key.pub")
+	pubKey, _ := BytesToPublicKey(pub)
+	first, err := Fingerprint(pubKey)
+	assert.Equal(t, err, nil)
+	second, _ := Fingerprint(pubKey)
+	assert.Equal(t, first, second)
+	assert.True(t, strings.HasPrefix(first, "SHA256:"))
+}
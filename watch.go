@@ -0,0 +1,180 @@
+package fazpass
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/anvarisy/go-fazpass-sdk/utils"
+)
+
+// Defaults of WatchConfig.
+const (
+	// DefaultWatchInterval is how often WatchKeys polls the files by default.
+	DefaultWatchInterval = 10 * time.Second
+	// DefaultKeepPreviousKeys is how many previous keys a keyring keeps across
+	// reloads by default.
+	DefaultKeepPreviousKeys = 2
+)
+
+// WatchConfig tells WatchKeys which files to poll. Empty paths are not watched.
+type WatchConfig struct {
+	PrivateKeyPath     string
+	PrivateKeyPassword []byte
+	PublicKeyPath      string
+	// KeepPreviousKeys is how many previous keys the keyring keeps when the
+	// private key is reloaded, older ones are dropped. DefaultKeepPreviousKeys
+	// when zero, a negative value keeps none.
+	KeepPreviousKeys int
+	// MerchantKeyPath is a file holding the merchant key, surrounding
+	// whitespace is ignored.
+	MerchantKeyPath string
+	// Interval between polls, DefaultWatchInterval when zero.
+	Interval time.Duration
+	// OnReload is called after a changed file was loaded.
+	OnReload func(event ReloadEvent)
+	// OnError is called when a changed file cannot be loaded, the previous
+	// value is kept.
+	OnError func(err error)
+}
+
+// ReloadEvent describes a file loaded by WatchKeys.
+type ReloadEvent struct {
+	// Kind is "private_key", "public_key" or "merchant_key".
+	Kind string
+	Path string
+	Time time.Time
+}
+
+type watchedFile struct {
+	kind string
+	path string
+	sum  [sha256.Size]byte
+	load func(content []byte) error
+}
+
+// WatchKeys polls the configured files until ctx is done and swaps the keys
+// and merchant key used by f when their content changes, which suits secrets
+// rotated in place. When a keyring is set, a new private key becomes its
+// primary key and the previous ones stay usable, up to
+// WatchConfig.KeepPreviousKeys of them. It fails when a file cannot
+// be read at start.
+func (f *Fazpass) WatchKeys(ctx context.Context, config WatchConfig) error {
+	files, err := f.watchedFiles(config)
+	if err != nil {
+		return err
+	}
+	interval := config.Interval
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				poll(files, config)
+			}
+		}
+	}()
+	return nil
+}
+
+func (f *Fazpass) watchedFiles(config WatchConfig) ([]*watchedFile, error) {
+	var files []*watchedFile
+	if config.PrivateKeyPath != "" {
+		if f.Decrypter != nil && f.Keyring == nil {
+			return nil, errors.New("private key file cannot be watched when a decrypter is set")
+		}
+		keep := config.KeepPreviousKeys
+		switch {
+		case keep == 0:
+			keep = DefaultKeepPreviousKeys
+		case keep < 0:
+			keep = 0
+		}
+		files = append(files, &watchedFile{kind: "private_key", path: config.PrivateKeyPath, load: func(content []byte) error {
+			key, err := utils.ParsePrivateKey(content, config.PrivateKeyPassword)
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidKey, err)
+			}
+			if f.Keyring != nil {
+				return f.Keyring.rotate("", key, keep)
+			}
+			f.mu.Lock()
+			f.PrivateKey = key
+			f.mu.Unlock()
+			return nil
+		}})
+	}
+	if config.PublicKeyPath != "" {
+		files = append(files, &watchedFile{kind: "public_key", path: config.PublicKeyPath, load: func(content []byte) error {
+			key, err := utils.ParsePublicKey(content)
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidKey, err)
+			}
+			f.mu.Lock()
+			f.PublicKey = key
+			f.mu.Unlock()
+			return nil
+		}})
+	}
+	if config.MerchantKeyPath != "" {
+		if f.Credentials != nil {
+			return nil, errors.New("merchant key file cannot be watched when credentials are set")
+		}
+		files = append(files, &watchedFile{kind: "merchant_key", path: config.MerchantKeyPath, load: func(content []byte) error {
+			merchantKey := string(bytes.TrimSpace(content))
+			if merchantKey == "" {
+				return errors.New("merchant key file is empty")
+			}
+			f.mu.Lock()
+			f.MerchantKey = merchantKey
+			f.mu.Unlock()
+			return nil
+		}})
+	}
+	if len(files) == 0 {
+		return nil, errors.New("no file to watch")
+	}
+	for _, file := range files {
+		content, err := os.ReadFile(file.path)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, file.path)
+		}
+		file.sum = sha256.Sum256(content)
+	}
+	return files, nil
+}
+
+// poll loads the files whose content changed since the previous poll.
+func poll(files []*watchedFile, config WatchConfig) {
+	for _, file := range files {
+		content, err := os.ReadFile(file.path)
+		if err != nil {
+			// The file may be missing for a moment while it is replaced.
+			continue
+		}
+		sum := sha256.Sum256(content)
+		if sum == file.sum {
+			continue
+		}
+		file.sum = sum
+		if err = file.load(content); err != nil {
+			if config.OnError != nil {
+				config.OnError(fmt.Errorf("reload %s: %w", file.path, err))
+			}
+			continue
+		}
+		if config.OnReload != nil {
+			config.OnReload(ReloadEvent{Kind: file.kind, Path: file.path, Time: time.Now()})
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package fazpass
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/anvarisy/go-fazpass-sdk/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchKeys(t *testing.T) {
+	priv, _ := os.ReadFile("key.priv")
+	pub, _ := os.ReadFile("key.pub")
+	newKey, _ := utils.GenerateKeyPair(2048)
+	newPub, _ := utils.PublicKeyToBytes(&newKey.PublicKey)
+
+	setup := func(t *testing.T, opts ...Option) (*Fazpass, string, string, string) {
+		dir := t.TempDir()
+		privPath := filepath.Join(dir, "key.priv")
+		pubPath := filepath.Join(dir, "key.pub")
+		merchantPath := filepath.Join(dir, "merchant_key")
+		os.WriteFile(privPath, priv, 0600)
+		os.WriteFile(pubPath, pub, 0600)
+		os.WriteFile(merchantPath, []byte("MERCHANT_KEY\n"), 0600)
+		f, _ := New(append([]Option{WithPrivateKeyPEM(priv), WithPublicKeyPEM(pub), WithMerchantKey("MERCHANT_KEY"), WithBaseURL("http://localhost")}, opts...)...)
+		return f, privPath, pubPath, merchantPath
+	}
+	watch := func(t *testing.T, f *Fazpass, config WatchConfig) (chan ReloadEvent, chan error) {
+		events := make(chan ReloadEvent, 10)
+		errs := make(chan error, 10)
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		config.Interval = 5 * time.Millisecond
+		config.OnReload = func(event ReloadEvent) { events <- event }
+		config.OnError = func(err error) { errs <- err }
+		assert.Equal(t, f.WatchKeys(ctx, config), nil)
+		return events, errs
+	}
+	nextEvent := func(t *testing.T, events chan ReloadEvent) ReloadEvent {
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(time.Second):
+			t.Fatal("file not reloaded")
+			return ReloadEvent{}
+		}
+	}
+	nextErr := func(t *testing.T, errs chan error) error {
+		select {
+		case err := <-errs:
+			return err
+		case <-time.After(time.Second):
+			t.Fatal("reload error not reported")
+			return nil
+		}
+	}
+
+	t.Run("Reload keys and merchant key", func(t *testing.T) {
+		f, privPath, pubPath, merchantPath := setup(t)
+		events, _ := watch(t, f, WatchConfig{PrivateKeyPath: privPath, PublicKeyPath: pubPath, MerchantKeyPath: merchantPath})
+
+		os.WriteFile(privPath, utils.PrivateKeyToBytes(newKey), 0600)
+		assert.Equal(t, nextEvent(t, events).Kind, "private_key")
+		os.WriteFile(pubPath, newPub, 0600)
+		assert.Equal(t, nextEvent(t, events).Kind, "public_key")
+		os.WriteFile(merchantPath, []byte("NEW_MERCHANT_KEY\n"), 0600)
+		assert.Equal(t, nextEvent(t, events).Kind, "merchant_key")
+
+		f.mu.RLock()
+		defer f.mu.RUnlock()
+		assert.True(t, f.PrivateKey.Equal(newKey))
+		assert.True(t, f.PublicKey.Equal(&newKey.PublicKey))
+		assert.Equal(t, f.MerchantKey, "NEW_MERCHANT_KEY")
+	})
+	t.Run("Keep keys when the new one is invalid", func(t *testing.T) {
+		f, privPath, _, _ := setup(t)
+		old := f.PrivateKey
+		_, errs := watch(t, f, WatchConfig{PrivateKeyPath: privPath})
+
+		os.WriteFile(privPath, []byte("KOALA_PANDA"), 0600)
+		assert.True(t, errors.Is(nextErr(t, errs), ErrInvalidKey))
+		f.mu.RLock()
+		defer f.mu.RUnlock()
+		assert.Equal(t, f.PrivateKey, old)
+	})
+	t.Run("Rotate keyring", func(t *testing.T) {
+		privKey, _ := utils.BytesToPrivateKey(priv)
+		keyring, _ := NewKeyring("old", privKey)
+		f, privPath, _, _ := setup(t, WithKeyring(keyring))
+		events, _ := watch(t, f, WatchConfig{PrivateKeyPath: privPath})
+
+		os.WriteFile(privPath, utils.PrivateKeyToBytes(newKey), 0600)
+		nextEvent(t, events)
+		assert.Equal(t, keyring.Primary(), newKey)
+		assert.Equal(t, keyring.IDs()[1], "old")
+	})
+	t.Run("Keep previous keys", func(t *testing.T) {
+		privKey, _ := utils.BytesToPrivateKey(priv)
+		keyring, _ := NewKeyring("old", privKey)
+		f, privPath, _, _ := setup(t, WithKeyring(keyring))
+		events, _ := watch(t, f, WatchConfig{PrivateKeyPath: privPath, KeepPreviousKeys: 1})
+
+		os.WriteFile(privPath, utils.PrivateKeyToBytes(newKey), 0600)
+		nextEvent(t, events)
+		latestKey, _ := utils.GenerateKeyPair(2048)
+		os.WriteFile(privPath, utils.PrivateKeyToBytes(latestKey), 0600)
+		nextEvent(t, events)
+		assert.Equal(t, keyring.Primary(), latestKey)
+		assert.Equal(t, len(keyring.IDs()), 2)
+		assert.NotContains(t, keyring.IDs(), "old")
+	})
+	t.Run("Merchant key from credentials", func(t *testing.T) {
+		f, _, _, merchantPath := setup(t, WithCredentialProvider(StaticCredentials("MERCHANT_KEY")))
+		err := f.WatchKeys(context.Background(), WatchConfig{MerchantKeyPath: merchantPath})
+		assert.Equal(t, err.Error(), "merchant key file cannot be watched when credentials are set")
+	})
+	t.Run("File not found", func(t *testing.T) {
+		f, _, _, _ := setup(t)
+		err := f.WatchKeys(context.Background(), WatchConfig{PublicKeyPath: "key"})
+		assert.True(t, errors.Is(err, ErrKeyNotFound))
+	})
+}